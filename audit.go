@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of the audit log.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Service  string    `json:"service,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Status   int64     `json:"status"`
+	BodyID   string    `json:"body_id"`
+	URL      string    `json:"url,omitempty"`
+	Headline string    `json:"headline,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// auditLog appends one JSON line per check to a file. It is shared by all
+// services. A nil *auditLog is valid and records nothing.
+type auditLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (a *auditLog) record(e auditEntry) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.enc.Encode(e); err != nil {
+		log.Printf("audit: write failed: %v", err)
+	}
+}
+
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.f.Close()
+}
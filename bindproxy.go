@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// resolveBindAddress turns bind_address, an IP address or a network
+// interface name, into the source address to use. For an interface it
+// prefers a global IPv6 address, then a global IPv4 one.
+func resolveBindAddress(s string) (net.IP, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(addrs, func(a net.Addr) bool { n, ok := a.(*net.IPNet); return ok && n.IP.Equal(ip) }) {
+			return nil, fmt.Errorf("%s is not an address of this host", ip)
+		}
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither an IP address nor a network interface", s)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var v4 net.IP
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok || !n.IP.IsGlobalUnicast() {
+			continue
+		}
+		if n.IP.To4() == nil {
+			return n.IP, nil
+		}
+		if v4 == nil {
+			v4 = n.IP
+		}
+	}
+	if v4 == nil {
+		return nil, fmt.Errorf("interface %s has no global address", s)
+	}
+	return v4, nil
+}
+
+// bindProxy is a minimal local HTTP proxy that makes every upstream
+// connection from one source address. Chrome has no flag to bind its
+// outgoing connections, so with bind_address it is pointed at this proxy
+// instead. It handles CONNECT tunnels (all HTTPS traffic) and plain HTTP.
+type bindProxy struct {
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	transport *http.Transport
+}
+
+// startBindProxy serves a bindProxy for ip on a random loopback port until
+// ctx is cancelled and returns its URL.
+func startBindProxy(ctx context.Context, ip net.IP) (*url.URL, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	// The family of the destination has to match the source address.
+	family := "tcp4"
+	if ip.To4() == nil {
+		family = "tcp6"
+	}
+	d := &net.Dialer{LocalAddr: &net.TCPAddr{IP: ip}, Timeout: 30 * time.Second}
+	bp := &bindProxy{dial: func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, family, addr)
+	}}
+	bp.transport = &http.Transport{DialContext: bp.dial}
+
+	srv := &http.Server{Handler: bp}
+	go srv.Serve(ln)
+	context.AfterFunc(ctx, func() { srv.Close() })
+	return &url.URL{Scheme: "http", Host: ln.Addr().String()}, nil
+}
+
+func (bp *bindProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		bp.tunnel(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "not a proxy request", http.StatusBadRequest)
+		return
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+	resp, err := bp.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// tunnel answers a CONNECT request and copies bytes both ways.
+func (bp *bindProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	up, err := bp.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		up.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		up.Close()
+		return
+	}
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		conn.Close()
+		up.Close()
+		return
+	}
+	go func() {
+		io.Copy(up, buf) // buf holds anything the client sent early
+		up.Close()
+	}()
+	io.Copy(conn, up)
+	conn.Close()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveBindAddress(t *testing.T) {
+	if ip, err := resolveBindAddress("127.0.0.1"); err != nil || !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("127.0.0.1: got %v, %v", ip, err)
+	}
+	for _, bad := range []string{"192.0.2.1", "no-such-iface0"} {
+		if _, err := resolveBindAddress(bad); err == nil {
+			t.Errorf("%s: want an error", bad)
+		}
+	}
+}
+
+func TestBindProxy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	proxyURL, err := startBindProxy(ctx, net.IPv4(127, 0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		io.WriteString(w, host)
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	tls := httptest.NewTLSServer(handler)
+	defer tls.Close()
+
+	for _, srv := range []*httptest.Server{plain, tls} {
+		client := srv.Client()
+		client.Transport.(*http.Transport).Proxy = http.ProxyURL(proxyURL)
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("%s: %v", srv.URL, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "127.0.0.1" {
+			t.Errorf("%s: connection came from %q", srv.URL, body)
+		}
+	}
+}
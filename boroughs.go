@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// boroughs maps the names accepted by --borough and the borough config key
+// to the dienstleister (location) IDs of the borough's Bürgerämter on
+// service.berlin.de. The IDs change rarely; appointment_url overrides a
+// preset that has gone stale.
+var boroughs = map[string][]string{
+	"charlottenburg-wilmersdorf": {"122208", "122226"},
+	"friedrichshain-kreuzberg":   {"122231", "122243", "122252"},
+	"lichtenberg":                {"122260", "122262", "122254"},
+	"marzahn-hellersdorf":        {"122271", "122273", "122277"},
+	"mitte":                      {"122210", "122217", "122219", "122227"},
+	"neukoelln":                  {"122280", "122282", "122284"},
+	"pankow":                     {"122285", "122286", "122291", "122296"},
+	"reinickendorf":              {"122294", "122297", "122301", "150230"},
+	"spandau":                    {"122304", "122312", "122314"},
+	"steglitz-zehlendorf":        {"122281", "122309", "122311"},
+	"tempelhof-schoeneberg":      {"122274", "122276", "122279"},
+	"treptow-koepenick":          {"122246", "122251", "122257", "122267"},
+}
+
+// serviceIDRe extracts the service (anliegen) ID from a service page URL.
+var serviceIDRe = regexp.MustCompile(`/dienstleistung/(\d+)`)
+
+// knownBoroughs lists the borough names for error messages.
+func knownBoroughs() string {
+	return strings.Join(slices.Sorted(maps.Keys(boroughs)), ", ")
+}
+
+// boroughURL builds the booking URL for the service at serviceURL in all of
+// the borough's locations.
+func boroughURL(borough, serviceURL string) (string, error) {
+	ids, ok := boroughs[strings.ToLower(borough)]
+	if !ok {
+		return "", fmt.Errorf("unknown borough %q (known: %s)", borough, knownBoroughs())
+	}
+	m := serviceIDRe.FindStringSubmatch(serviceURL)
+	if m == nil {
+		return "", fmt.Errorf("service_url %q has no /dienstleistung/<id>/ to book", serviceURL)
+	}
+	return fmt.Sprintf("https://service.berlin.de/terminvereinbarung/termin/tag.php?termin=1&anliegen[]=%s&dienstleisterlist=%s",
+		m[1], strings.Join(ids, ",")), nil
+}
+
+// applyBorough sets AppointmentURL from the Borough preset unless an
+// appointment_url or appointment_urls is configured explicitly.
+func (cfg *Config) applyBorough() {
+	if cfg.Borough == "" || cfg.AppointmentURL != "" || len(cfg.AppointmentURLs) > 0 {
+		return
+	}
+	u, err := boroughURL(cfg.Borough, cfg.ServiceURL)
+	if err != nil {
+		cfg.problemf("borough: %v — clicking through to Mitte instead", err)
+		cfg.Borough = ""
+		return
+	}
+	cfg.AppointmentURL = u
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBoroughURL(t *testing.T) {
+	u, err := boroughURL("Pankow", "https://service.berlin.de/dienstleistung/120686/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "anliegen[]=120686&dienstleisterlist=" + strings.Join(boroughs["pankow"], ","); !strings.HasSuffix(u, want) {
+		t.Errorf("got %s, want it to end in %s", u, want)
+	}
+
+	if _, err := boroughURL("atlantis", defaultServiceURL); err == nil || !strings.Contains(err.Error(), "neukoelln") {
+		t.Errorf("unknown borough: got %v, want an error listing the known ones", err)
+	}
+	if _, err := boroughURL("mitte", "https://example.com/"); err == nil {
+		t.Error("want an error for a service URL without a service id")
+	}
+}
+
+func TestApplyBorough(t *testing.T) {
+	cfg := &Config{Borough: "mitte", ServiceURL: defaultServiceURL}
+	cfg.forServices()
+	if !strings.Contains(cfg.AppointmentURL, "anliegen[]=351180") {
+		t.Errorf("AppointmentURL = %q, want the preset", cfg.AppointmentURL)
+	}
+
+	explicit := "https://service.berlin.de/terminvereinbarung/termin/tag.php?termin=1&dienstleister=122210&anliegen[]=120686"
+	cfg = &Config{Borough: "mitte", ServiceURL: defaultServiceURL, AppointmentURL: explicit}
+	cfg.forServices()
+	if cfg.AppointmentURL != explicit {
+		t.Errorf("AppointmentURL = %q, want the explicit one to win", cfg.AppointmentURL)
+	}
+
+	cfg = &Config{Services: []ServiceConfig{{Name: "a", ServiceURL: defaultServiceURL, Borough: "spandau"}, {Name: "b", ServiceURL: defaultServiceURL}}}
+	svcs := cfg.forServices()
+	if !strings.Contains(svcs[0].AppointmentURL, boroughs["spandau"][0]) || svcs[1].AppointmentURL != "" {
+		t.Errorf("per-service borough: got %q and %q", svcs[0].AppointmentURL, svcs[1].AppointmentURL)
+	}
+
+	cfg = &Config{Borough: "pankow", Services: []ServiceConfig{
+		{Name: "a", ServiceURL: defaultServiceURL},
+		{Name: "b", ServiceURL: defaultServiceURL, Borough: "spandau"},
+	}}
+	svcs = cfg.forServices()
+	if !strings.Contains(svcs[0].AppointmentURL, boroughs["pankow"][0]) || svcs[0].Borough != "pankow" {
+		t.Errorf("inherited borough: got %q (borough %q), want the pankow preset", svcs[0].AppointmentURL, svcs[0].Borough)
+	}
+	if !strings.Contains(svcs[1].AppointmentURL, boroughs["spandau"][0]) {
+		t.Errorf("overridden borough: got %q, want the spandau preset", svcs[1].AppointmentURL)
+	}
+
+	cfg = &Config{Borough: "atlantis"}
+	cfg.validate()
+	if cfg.Borough != "" || len(cfg.problems) == 0 {
+		t.Error("validate should drop an unknown borough")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// breakerState is the state of a circuitBreaker.
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // checking at the normal cadence
+	breakerOpen                         // the site looks down; waiting out the cooldown
+	breakerHalfOpen                     // the next check is a single probe
+)
+
+func (b breakerState) String() string {
+	switch b {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops checking a site that is clearly down. After threshold
+// consecutive hard failures (errors and 5xx responses) it opens and asks for
+// a cooldown wait; the check after that is a probe, which closes the breaker
+// on success and reopens it on failure. A nil *circuitBreaker never trips.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	log       *slog.Logger
+
+	state    breakerState
+	failures int // consecutive hard failures while closed
+}
+
+// newCircuitBreaker returns nil when threshold is 0, disabling the breaker.
+func newCircuitBreaker(threshold int, cooldown time.Duration, log *slog.Logger) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, log: log}
+}
+
+// beforeCheck moves an open breaker to half-open, making the coming check
+// the probe. It is called once the cooldown has been waited out.
+func (b *circuitBreaker) beforeCheck() {
+	if b == nil || b.state != breakerOpen {
+		return
+	}
+	b.transition(breakerHalfOpen, "probing whether the site is back")
+}
+
+// record feeds the result of a check into the breaker and returns the
+// cooldown to wait when it opens, 0 otherwise.
+func (b *circuitBreaker) record(hardFailure bool) time.Duration {
+	if b == nil {
+		return 0
+	}
+	switch {
+	case b.state == breakerHalfOpen && hardFailure:
+		b.transition(breakerOpen, "probe failed")
+		return b.cooldown
+	case b.state == breakerHalfOpen:
+		b.failures = 0
+		b.transition(breakerClosed, "probe succeeded, resuming normal checks")
+	case !hardFailure:
+		b.failures = 0
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.failures = 0
+			b.transition(breakerOpen, "site looks down")
+			return b.cooldown
+		}
+	}
+	return 0
+}
+
+func (b *circuitBreaker) transition(to breakerState, reason string) {
+	b.log.Warn("circuit breaker "+to.String(), "from", b.state.String(), "reason", reason, "cooldown", b.cooldown.String())
+	b.state = to
+}
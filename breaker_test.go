@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(3, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// check runs one check with the given result and returns the wait the
+	// breaker asks for and its state afterwards.
+	check := func(hardFailure bool) (time.Duration, breakerState) {
+		b.beforeCheck()
+		return b.record(hardFailure), b.state
+	}
+
+	steps := []struct {
+		hardFailure bool
+		wait        time.Duration
+		state       breakerState
+	}{
+		{true, 0, breakerClosed},
+		{true, 0, breakerClosed},
+		{false, 0, breakerClosed}, // resets the count
+		{true, 0, breakerClosed},
+		{true, 0, breakerClosed},
+		{true, time.Hour, breakerOpen},
+		{true, time.Hour, breakerOpen}, // failed probe reopens
+		{false, 0, breakerClosed},      // successful probe closes
+		{true, 0, breakerClosed},
+	}
+	for i, st := range steps {
+		wait, state := check(st.hardFailure)
+		if wait != st.wait || state != st.state {
+			t.Fatalf("step %d: got wait %v state %v, want %v %v", i, wait, state, st.wait, st.state)
+		}
+	}
+}
+
+func TestCircuitBreakerNil(t *testing.T) {
+	var b *circuitBreaker
+	b.beforeCheck()
+	if wait := b.record(true); wait != 0 {
+		t.Errorf("nil breaker asked for wait %v", wait)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// checkBudget is a token bucket capping page loads per hour across all
+// services, whatever the interval, jitter and backoff. It holds one token,
+// so checks are spread out evenly rather than bursting. A nil *checkBudget
+// never limits.
+type checkBudget struct {
+	perHour int
+	every   time.Duration // time to earn one token
+	clock   Clock
+
+	mu     sync.Mutex
+	tokens float64 // may go negative: tokens reserved ahead of time
+	last   time.Time
+}
+
+// newCheckBudget returns nil when perHour is 0, disabling the budget.
+func newCheckBudget(perHour int, clock Clock) *checkBudget {
+	if perHour <= 0 {
+		return nil
+	}
+	return &checkBudget{
+		perHour: perHour,
+		every:   time.Hour / time.Duration(perHour),
+		clock:   clock,
+		tokens:  1,
+		last:    clock.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait before it may be used;
+// 0 means the check can run now.
+func (b *checkBudget) reserve() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	b.tokens = min(b.tokens+float64(now.Sub(b.last))/float64(b.every), 1)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens * float64(b.every))
+}
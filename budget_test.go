@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckBudget(t *testing.T) {
+	clock := newFakeClock()
+	b := newCheckBudget(6, clock) // one check every 10 minutes
+
+	if wait := b.reserve(); wait != 0 {
+		t.Fatalf("first check waits %v", wait)
+	}
+	if wait := b.reserve(); wait != 10*time.Minute {
+		t.Fatalf("second check waits %v, want 10m", wait)
+	}
+	// A third reservation queues behind the second.
+	if wait := b.reserve(); wait != 20*time.Minute {
+		t.Fatalf("third check waits %v, want 20m", wait)
+	}
+	clock.Advance(time.Hour)
+	if wait := b.reserve(); wait != 0 {
+		t.Fatalf("check after an idle hour waits %v", wait)
+	}
+	if wait := b.reserve(); wait != 10*time.Minute {
+		t.Fatalf("tokens accumulated beyond one: wait %v", wait)
+	}
+}
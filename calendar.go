@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// bookableLinksJS collects the hrefs of the bookable days on the dayselect
+// calendar. Each links to .../termin/time/<unix midnight>/.
+const bookableLinksJS = `Array.from(document.querySelectorAll('td.buchbar a')).map(a => a.getAttribute('href') || '')`
+
+// timeSlotsJS collects the row labels of the bookable times on the time
+// selection page a day link leads to.
+const timeSlotsJS = `Array.from(document.querySelectorAll('.timetable tr')).filter(tr => tr.querySelector('td.frei a, a[href*="/termin/"]')).map(tr => (tr.querySelector('th') || tr).textContent.trim())`
+
+var (
+	dayLinkRe  = regexp.MustCompile(`/time/(\d+)/?`)
+	slotTimeRe = regexp.MustCompile(`\b([01]?\d|2[0-3]):([0-5]\d)\b`)
+)
+
+// berlinLocation returns the Europe/Berlin time zone the booking calendar
+// uses, or local time if it can't be loaded.
+func berlinLocation() *time.Location {
+	if loc, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		return loc
+	}
+	return time.Local
+}
+
+// availability describes the number of bookable days for logs and messages.
+// No links means the calendar markup wasn't recognized, not zero days.
+func availability(dayLinks []string) string {
+	switch n := len(dayLinks); n {
+	case 0:
+		return "availability unknown"
+	case 1:
+		return "1 day with slots"
+	default:
+		return fmt.Sprintf("%d days with slots", n)
+	}
+}
+
+// parseAvailableDates extracts the bookable days from the calendar links.
+// Links that don't carry a timestamp are skipped.
+func parseAvailableDates(hrefs []string) []time.Time {
+	var dates []time.Time
+	for _, h := range hrefs {
+		m := dayLinkRe.FindStringSubmatch(h)
+		if m == nil {
+			continue
+		}
+		sec, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, time.Unix(sec, 0))
+	}
+	return dates
+}
+
+// parseTimeSlots extracts the HH:MM times from the time table labels, in page
+// order and without duplicates.
+func parseTimeSlots(labels []string) []string {
+	var times []string
+	for _, l := range labels {
+		m := slotTimeRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		h := m[1]
+		if len(h) == 1 {
+			h = "0" + h
+		}
+		t := h + ":" + m[2]
+		if !slices.Contains(times, t) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// formatDates formats days as YYYY-MM-DD in Berlin time.
+func formatDates(days []time.Time) []string {
+	var out []string
+	for _, d := range days {
+		out = append(out, d.In(berlinLocation()).Format(time.DateOnly))
+	}
+	return out
+}
+
+// dateFilter restricts which available days count as a hit. A zero value
+// accepts everything.
+type dateFilter struct {
+	from   time.Time     // inclusive; zero means no lower bound
+	until  time.Time     // exclusive; zero means no upper bound
+	within time.Duration // only days before now+within; zero means no limit
+}
+
+func (f dateFilter) active() bool {
+	return !f.from.IsZero() || !f.until.IsZero() || f.within > 0
+}
+
+// match reports whether any of dates passes the filter.
+func (f dateFilter) match(dates []time.Time, now time.Time) bool {
+	return slices.ContainsFunc(dates, func(d time.Time) bool { return f.accepts(d, now) })
+}
+
+// accepts reports whether the single day d passes the filter.
+func (f dateFilter) accepts(d, now time.Time) bool {
+	switch {
+	case !f.from.IsZero() && d.Before(f.from):
+		return false
+	case !f.until.IsZero() && !d.Before(f.until):
+		return false
+	case f.within > 0 && !d.Before(now.Add(f.within)):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires every After that is due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	c := newFakeClock()
+	ch := c.After(time.Minute)
+
+	c.Advance(59 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After fired before its deadline")
+	default:
+	}
+
+	c.Advance(time.Second)
+	select {
+	case <-ch:
+	default:
+		t.Fatal("After did not fire at its deadline")
+	}
+}
+
+func TestCooldownThrottle(t *testing.T) {
+	c := newFakeClock()
+	th := newCooldownThrottle(10*time.Minute, c)
+
+	steps := []struct {
+		advance time.Duration
+		want    bool
+	}{
+		{0, true},                // first success notifies
+		{time.Minute, false},     // within the cooldown
+		{8 * time.Minute, false}, // 9m after the notification
+		{time.Minute, true},      // cooldown over
+		{0, false},               // and a new one starts
+	}
+	for i, st := range steps {
+		c.Advance(st.advance)
+		if got := th.onSuccess(); got != st.want {
+			t.Errorf("step %d: onSuccess() = %v, want %v", i, got, st.want)
+		}
+	}
+}
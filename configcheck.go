@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// problemf logs a configuration problem and records it for --validate-config.
+func (cfg *Config) problemf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("config: %s", msg)
+	cfg.problems = append(cfg.problems, msg)
+}
+
+// checkConfig implements --validate-config: it loads path the way a normal
+// start would, also rejecting unknown keys, prints what is enabled and
+// returns 0 if nothing was wrong, 1 otherwise. It neither starts Chrome nor
+// touches the network.
+func checkConfig(path string) int {
+	data, err := readConfigFile(path)
+	if err != nil {
+		fmt.Printf("✗ %v\n", err)
+		return 1
+	}
+	// Problems are listed at the end instead of logged as they are found.
+	log.SetOutput(io.Discard)
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		fmt.Printf("✗ %s: %v\n", path, err)
+		return 1
+	}
+	applyEnv(&cfg, os.LookupEnv)
+	cfg.validate()
+	for _, raw := range append([]string{cfg.ProxyURL}, cfg.Proxies...) {
+		if raw == "" {
+			continue
+		}
+		if _, err := parseProxy(raw); err != nil {
+			cfg.problemf("proxy %s: %v", redactURL(raw), err)
+		}
+	}
+
+	fmt.Printf("%s:\n", path)
+	for _, c := range cfg.forServices() {
+		name := c.name
+		if name == "" {
+			name = "service"
+		}
+		fmt.Printf("  %s → %s\n", name, c.ServiceURL)
+	}
+	var names []string
+	for _, n := range newNotifiers(&cfg) {
+		names = append(names, n.Name())
+	}
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	fmt.Printf("  notifiers: %s\n", strings.Join(names, ", "))
+	for _, u := range cfg.WebhookURLs {
+		fmt.Printf("  webhook → %s (%s)\n", redactURL(u), cfg.WebhookMethod)
+	}
+	if cfg.ErrorWebhookURL != "" {
+		fmt.Printf("  error alerts → %s after %d failures\n", redactURL(cfg.ErrorWebhookURL), cfg.ErrorThreshold)
+	}
+	if cfg.HeartbeatInterval > 0 {
+		fmt.Printf("  heartbeat every %s\n", cfg.HeartbeatInterval)
+	}
+	if cfg.quietLoc != nil {
+		fmt.Printf("  quiet hours %s–%s\n", cfg.QuietHoursStart, cfg.QuietHoursEnd)
+	}
+	if cfg.activeOn {
+		fmt.Printf("  active hours %s–%s\n", cfg.ActiveHoursStart, cfg.ActiveHoursEnd)
+	}
+
+	if len(cfg.problems) > 0 {
+		fmt.Printf("✗ %d problem(s):\n", len(cfg.problems))
+		for _, p := range cfg.problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		return 1
+	}
+	fmt.Println("✓ config is valid")
+	return 0
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads the config file at path and returns it as YAML, so
+// the yaml tags on Config stay the only key names. The format follows the
+// extension: .toml is converted, .json is already valid YAML, and anything
+// else (.yaml, .yml, unknown) is read as YAML.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(path)) != ".toml" {
+		return data, nil
+	}
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
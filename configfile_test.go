@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+service_url: "https://service.berlin.de/dienstleistung/120335/"
+webhook_urls: ["https://ntfy.sh/a", "https://ntfy.sh/b"]
+interval: 2m
+services:
+  - name: abmeldung
+    service_url: "https://service.berlin.de/dienstleistung/120335/"
+`,
+		"config.json": `{
+  "service_url": "https://service.berlin.de/dienstleistung/120335/",
+  "webhook_urls": ["https://ntfy.sh/a", "https://ntfy.sh/b"],
+  "interval": "2m",
+  "services": [{"name": "abmeldung", "service_url": "https://service.berlin.de/dienstleistung/120335/"}]
+}`,
+		"config.toml": `
+service_url = "https://service.berlin.de/dienstleistung/120335/"
+webhook_urls = ["https://ntfy.sh/a", "https://ntfy.sh/b"]
+interval = "2m"
+
+[[services]]
+name = "abmeldung"
+service_url = "https://service.berlin.de/dienstleistung/120335/"
+`,
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := loadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ServiceURL != "https://service.berlin.de/dienstleistung/120335/" {
+				t.Errorf("service_url = %q", cfg.ServiceURL)
+			}
+			if !slices.Equal(cfg.WebhookURLs, []string{"https://ntfy.sh/a", "https://ntfy.sh/b"}) {
+				t.Errorf("webhook_urls = %v", cfg.WebhookURLs)
+			}
+			if cfg.Interval != 2*time.Minute {
+				t.Errorf("interval = %s, want 2m", cfg.Interval)
+			}
+			if len(cfg.Services) != 1 || cfg.Services[0].Name != "abmeldung" {
+				t.Errorf("services = %+v", cfg.Services)
+			}
+			if cfg.TakenBodyID != "taken" {
+				t.Errorf("taken_body_id = %q, want the default from validate", cfg.TakenBodyID)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// consentJS clicks the element matching a selector if it is shown and
+// reports "clicked", "absent" or "invalid" (a selector querySelector rejects).
+const consentJS = `(sel => {
+	let el;
+	try { el = document.querySelector(sel); } catch (e) { return "invalid"; }
+	if (!el || el.getClientRects().length === 0) return "absent";
+	el.click();
+	return "clicked";
+})(%s)`
+
+// dismissConsent accepts the cookie-consent banner by clicking
+// cfg.ConsentSelector, so its overlay neither swallows the clicks that follow
+// nor covers the page that is read. Without the banner it does nothing, as
+// it does when consent_selector is unset.
+func (s *sniper) dismissConsent(cfg *Config) chromedp.Action {
+	if cfg.ConsentSelector == "" {
+		return chromedp.Tasks{}
+	}
+	sel, _ := json.Marshal(cfg.ConsentSelector)
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var res string
+		if err := chromedp.Evaluate(fmt.Sprintf(consentJS, sel), &res).Do(ctx); err != nil {
+			return err
+		}
+		switch res {
+		case "clicked":
+			s.log.Info("dismissed cookie banner", "selector", cfg.ConsentSelector)
+			// Give the overlay a moment to go away before the next click.
+			return chromedp.Sleep(500 * time.Millisecond).Do(ctx)
+		case "invalid":
+			s.log.Warn("consent_selector is not a valid CSS selector", "selector", cfg.ConsentSelector)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDismissConsent(t *testing.T) {
+	s := &sniper{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	if tasks, ok := s.dismissConsent(&Config{}).(chromedp.Tasks); !ok || len(tasks) != 0 {
+		t.Errorf("without consent_selector: %#v, want no tasks", s.dismissConsent(&Config{}))
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal([]byte(`consent_selector: 'button[data-action="accept"]'`), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConsentSelector != `button[data-action="accept"]` {
+		t.Errorf("consent_selector = %q", cfg.ConsentSelector)
+	}
+	if _, ok := s.dismissConsent(cfg).(chromedp.ActionFunc); !ok {
+		t.Error("with consent_selector: want the dismiss action")
+	}
+}
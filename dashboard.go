@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// dashboardRecent is how many checks the dashboard lists.
+const dashboardRecent = 20
+
+// dashboard keeps the latest checks of all services in memory and renders
+// them as a small HTML status page. A nil *dashboard is valid and records
+// nothing.
+type dashboard struct {
+	mu       sync.Mutex
+	started  time.Time
+	recent   []auditEntry          // oldest first, at most dashboardRecent
+	latest   map[string]auditEntry // by service name
+	throttle map[string]string     // by service name
+}
+
+func newDashboard() *dashboard {
+	return &dashboard{
+		started:  time.Now(),
+		latest:   make(map[string]auditEntry),
+		throttle: make(map[string]string),
+	}
+}
+
+// record adds a completed check and the throttle state after it.
+func (d *dashboard) record(e auditEntry, throttle string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recent = append(d.recent, e)
+	if len(d.recent) > dashboardRecent {
+		d.recent = d.recent[len(d.recent)-dashboardRecent:]
+	}
+	d.latest[e.Service] = e
+	d.throttle[e.Service] = throttle
+}
+
+type dashboardService struct {
+	Last     auditEntry
+	Throttle string
+}
+
+var dashboardTmpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"ago": func(t time.Time) string { return time.Since(t).Round(time.Second).String() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="30">
+<title>terminator</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 1em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 0.3em 0.5em; border-bottom: 1px solid #ddd; }
+.success { background: #cfc; }
+.error, .captcha, .blocked, .unexpected { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>terminator</h1>
+<p>Running for {{ago .Started}}.</p>
+{{range .Services}}
+<h2>{{or .Last.Service "Appointments"}}</h2>
+<table>
+<tr><th>Last check</th><td>{{.Last.Time.Format "15:04:05"}} ({{ago .Last.Time}} ago)</td></tr>
+<tr><th>Outcome</th><td class="{{.Last.Outcome}}">{{.Last.Outcome}}</td></tr>
+<tr><th>Status</th><td>{{.Last.Status}}</td></tr>
+<tr><th>Body id</th><td>{{.Last.BodyID}}</td></tr>
+<tr><th>Headline</th><td>{{.Last.Headline}}</td></tr>
+{{with .Last.Error}}<tr><th>Error</th><td>{{.}}</td></tr>{{end}}
+<tr><th>Throttle</th><td>{{.Throttle}}</td></tr>
+</table>
+{{else}}
+<p>No checks yet.</p>
+{{end}}
+{{with .Recent}}
+<h2>Recent checks</h2>
+<table>
+<tr><th>Time</th><th>Service</th><th>Outcome</th><th>Status</th><th>Body id</th></tr>
+{{range .}}<tr class="{{.Outcome}}"><td>{{.Time.Format "15:04:05"}}</td><td>{{.Service}}</td><td>{{.Outcome}}</td><td>{{.Status}}</td><td>{{.BodyID}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	d.mu.Lock()
+	names := make([]string, 0, len(d.latest))
+	for name := range d.latest {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	services := make([]dashboardService, len(names))
+	for i, name := range names {
+		services[i] = dashboardService{Last: d.latest[name], Throttle: d.throttle[name]}
+	}
+	recent := slices.Clone(d.recent)
+	d.mu.Unlock()
+	slices.Reverse(recent)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTmpl.Execute(w, struct {
+		Started  time.Time
+		Services []dashboardService
+		Recent   []auditEntry
+	}{d.started, services, recent})
+	if err != nil {
+		log.Printf("dashboard: %v", err)
+	}
+}
+
+// serveDashboard exposes d on addr at / until ctx is cancelled.
+func serveDashboard(ctx context.Context, addr string, d *dashboard) {
+	mux := http.NewServeMux()
+	mux.Handle("/", d)
+	serve(ctx, "dashboard", addr, mux)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboard(t *testing.T) {
+	d := newDashboard()
+	for range dashboardRecent + 5 {
+		d.record(auditEntry{Time: time.Now(), Outcome: "known", Status: 200, BodyID: "taken"}, "0 consecutive")
+	}
+	d.record(auditEntry{Time: time.Now(), Outcome: "error", Error: "<timeout>"}, "0 consecutive")
+
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	body := w.Body.String()
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, body)
+	}
+	if !strings.Contains(body, "&lt;timeout&gt;") {
+		t.Error("error not shown or not escaped")
+	}
+	if rows := strings.Count(body, `<tr class="`); rows != dashboardRecent {
+		t.Errorf("%d recent rows, want %d", rows, dashboardRecent)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// debugHTMLMax caps the size of a page dump written to --debug-dir.
+const debugHTMLMax = 1 << 20
+
+// dumpPage writes the HTML of an unexpected page to a timestamped file in
+// s.debugDir, so whatever the site changed can be inspected later.
+func (s *sniper) dumpPage(p pageState, at time.Time) {
+	if s.debugDir == "" || p.html == "" {
+		return
+	}
+	name := "unexpected-" + at.Format("20060102-150405")
+	if s.cfg.name != "" {
+		name += "-" + s.cfg.name
+	}
+	path := filepath.Join(s.debugDir, name+".html")
+	html := p.html
+	if len(html) > debugHTMLMax {
+		html = html[:debugHTMLMax]
+	}
+	if err := os.WriteFile(path, []byte(html), 0o644); err != nil {
+		s.logf("debug: could not write %s (%v)", path, err)
+		return
+	}
+	s.log.Info("page saved", "path", path, "bytes", len(html))
+}
@@ -0,0 +1,49 @@
+package main
+
+import "strings"
+
+// defaultCaptchaMarkers are matched case-insensitively against the page's
+// body id, title, body class and headline when captcha_markers is unset.
+var defaultCaptchaMarkers = []string{
+	"captcha",
+	"just a moment",
+	"verify you are human",
+	"cf-challenge",
+	"sicherheitsabfrage",
+}
+
+// defaultBlockMarkers identify WAF block pages (Cloudflare, Akamai, F5 and
+// similar) when block_markers is unset. They are matched like the captcha
+// markers.
+var defaultBlockMarkers = []string{
+	"cf-error",
+	"attention required",
+	"you have been blocked",
+	"access denied",
+	"request blocked",
+	"requested url was rejected",
+}
+
+// pageHintsJS returns the page title and body class, plus "captcha" when a
+// known challenge widget (reCAPTCHA, hCaptcha, Cloudflare Turnstile or
+// challenge form) is on the page and "cf-error" on a Cloudflare error page.
+const pageHintsJS = `[
+	document.title,
+	document.body ? document.body.className : '',
+	document.querySelector('.g-recaptcha, .h-captcha, .cf-turnstile, #challenge-form, iframe[src*="captcha"], iframe[src*="challenges.cloudflare.com"]') ? 'captcha' : '',
+	document.querySelector('#cf-error-details, .cf-error-details') ? 'cf-error' : ''
+].join(' ')`
+
+// matchMarker returns the first marker contained in any of texts, ignoring
+// case, or "" if none match.
+func matchMarker(markers []string, texts ...string) string {
+	for _, t := range texts {
+		t = strings.ToLower(t)
+		for _, m := range markers {
+			if m != "" && strings.Contains(t, strings.ToLower(m)) {
+				return m
+			}
+		}
+	}
+	return ""
+}
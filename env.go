@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the environment variables that override config keys:
+// webhook_url is TERMINATOR_WEBHOOK_URL, interval is TERMINATOR_INTERVAL.
+const envPrefix = "TERMINATOR_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// yamlKey returns the YAML key of a Config field, or "" for fields that are
+// not read from the file.
+func yamlKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// applyEnv overrides cfg with TERMINATOR_<KEY> variables found by lookup, so
+// the environment takes precedence over the config file. Lists are
+// comma-separated and maps are comma-separated key=value pairs; keys made of
+// nested settings (services, pre_steps, success_text, notifier_throttles)
+// take their value as JSON or YAML. Values that don't parse are logged and
+// ignored.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := range t.NumField() {
+		key := yamlKey(t.Field(i))
+		if key == "" {
+			continue
+		}
+		name := envPrefix + strings.ToUpper(key)
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), raw); err != nil {
+			cfg.problemf("%s: %v — ignored", name, err)
+		}
+	}
+}
+
+// setField parses raw into f according to f's type.
+func setField(f reflect.Value, raw string) error {
+	if composite(f.Type()) {
+		dec := yaml.NewDecoder(strings.NewReader(raw))
+		dec.KnownFields(true)
+		p := reflect.New(f.Type())
+		if err := dec.Decode(p.Interface()); err != nil {
+			return err
+		}
+		f.Set(p.Elem())
+		return nil
+	}
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(x)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Pointer:
+		p := reflect.New(f.Type().Elem())
+		if err := setField(p.Elem(), raw); err != nil {
+			return err
+		}
+		f.Set(p)
+	case reflect.Slice:
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not key=value", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		f.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
+
+// composite reports whether values of t hold nested settings, which don't
+// fit the comma-separated forms and are decoded as JSON or YAML instead.
+func composite(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Pointer:
+		return composite(t.Elem())
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.String
+	case reflect.Map:
+		return t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String
+	}
+	return false
+}
+
+// effectiveConfig describes every config key that is set, for the startup
+// log. Fields tagged secret:"true" are masked with redactSecret.
+func effectiveConfig(cfg *Config) string {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	var parts []string
+	for i := range t.NumField() {
+		key := yamlKey(t.Field(i))
+		f := v.Field(i)
+		if key == "" || key == "services" || f.IsZero() {
+			continue
+		}
+		var val string
+		switch {
+		case t.Field(i).Tag.Get("secret") == "true" && f.Kind() == reflect.String:
+			val = redactSecret(f.String())
+		case t.Field(i).Tag.Get("secret") == "true" && f.Kind() == reflect.Slice:
+			masked := make([]string, f.Len())
+			for j := range masked {
+				masked[j] = redactSecret(f.Index(j).String())
+			}
+			val = fmt.Sprint(masked)
+		case t.Field(i).Tag.Get("secret") == "true":
+			val = "***"
+		case f.Kind() == reflect.Pointer:
+			val = fmt.Sprint(f.Elem().Interface())
+		default:
+			val = fmt.Sprint(f.Interface())
+		}
+		parts = append(parts, key+"="+val)
+	}
+	return strings.Join(parts, " ")
+}
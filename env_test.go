@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"TERMINATOR_WEBHOOK_URL":     "https://example.com/hook",
+		"TERMINATOR_INTERVAL":        "90s",
+		"TERMINATOR_SMTP_PORT":       "587",
+		"TERMINATOR_WEIGHT":          "2.5",
+		"TERMINATOR_HEADLESS":        "false",
+		"TERMINATOR_USER_AGENTS":     "a, b,",
+		"TERMINATOR_WEBHOOK_HEADERS": "Authorization=Bearer x,X-Env=1",
+		"TERMINATOR_ERROR_THRESHOLD": "many",
+	}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+
+	cfg := Config{ErrorThreshold: 3, WebhookURL: "https://file.example/hook"}
+	applyEnv(&cfg, lookup)
+
+	if cfg.WebhookURL != "https://example.com/hook" {
+		t.Errorf("WebhookURL = %q, want the environment to win over the file", cfg.WebhookURL)
+	}
+	if cfg.Interval != 90*time.Second {
+		t.Errorf("Interval = %v", cfg.Interval)
+	}
+	if cfg.SMTPPort != 587 {
+		t.Errorf("SMTPPort = %d", cfg.SMTPPort)
+	}
+	if cfg.Weight != 2.5 {
+		t.Errorf("Weight = %g", cfg.Weight)
+	}
+	if cfg.Headless == nil || *cfg.Headless {
+		t.Errorf("Headless = %v", cfg.Headless)
+	}
+	if !reflect.DeepEqual(cfg.UserAgents, []string{"a", "b"}) {
+		t.Errorf("UserAgents = %q", cfg.UserAgents)
+	}
+	if want := map[string]string{"Authorization": "Bearer x", "X-Env": "1"}; !reflect.DeepEqual(cfg.WebhookHeaders, want) {
+		t.Errorf("WebhookHeaders = %v", cfg.WebhookHeaders)
+	}
+	if cfg.ErrorThreshold != 3 {
+		t.Errorf("ErrorThreshold = %d, want an unparseable value to be ignored", cfg.ErrorThreshold)
+	}
+}
+
+func TestApplyEnvNested(t *testing.T) {
+	env := map[string]string{
+		"TERMINATOR_NOTIFIER_THROTTLES": `{"telegram":{"window":0},"email":{"cooldown":"90s"}}`,
+		"TERMINATOR_PRE_STEPS":          `[{action: click, selector: "#more"}]`,
+		"TERMINATOR_SUCCESS_TEXT":       `{"contains":["Termin"],"match":"any"}`,
+		"TERMINATOR_SERVICES":           `[{"name":"Pass","service_url":"https://example.com/pass"}]`,
+	}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+
+	var cfg Config
+	applyEnv(&cfg, lookup)
+
+	want := map[string]NotifierThrottle{"telegram": {}, "email": {Cooldown: 90 * time.Second}}
+	if !reflect.DeepEqual(cfg.NotifierThrottles, want) {
+		t.Errorf("NotifierThrottles = %+v", cfg.NotifierThrottles)
+	}
+	if want := []PreStep{{Action: "click", Selector: "#more"}}; !reflect.DeepEqual(cfg.PreSteps, want) {
+		t.Errorf("PreSteps = %+v", cfg.PreSteps)
+	}
+	if cfg.SuccessText == nil || cfg.SuccessText.Match != "any" || !reflect.DeepEqual(cfg.SuccessText.Contains, []string{"Termin"}) {
+		t.Errorf("SuccessText = %+v", cfg.SuccessText)
+	}
+	if len(cfg.Services) != 1 || cfg.Services[0].Name != "Pass" || cfg.Services[0].ServiceURL != "https://example.com/pass" {
+		t.Errorf("Services = %+v", cfg.Services)
+	}
+
+	cfg = Config{}
+	env = map[string]string{"TERMINATOR_SUCCESS_TEXT": `{"contians":["Termin"]}`}
+	applyEnv(&cfg, lookup)
+	if cfg.SuccessText != nil || len(cfg.problems) != 1 {
+		t.Errorf("SuccessText = %+v, problems = %q, want an unknown key to be reported and ignored", cfg.SuccessText, cfg.problems)
+	}
+}
+
+func TestEffectiveConfigMasksSecrets(t *testing.T) {
+	cfg := Config{TelegramBotToken: "123:abc", TelegramChatID: "42"}
+	got := effectiveConfig(&cfg)
+	if strings.Contains(got, "123:abc") || !strings.Contains(got, "telegram_bot_token=***") {
+		t.Errorf("token not masked: %s", got)
+	}
+	if !strings.Contains(got, "telegram_chat_id=42") {
+		t.Errorf("chat id missing: %s", got)
+	}
+}
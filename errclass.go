@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// errorClass is the kind of failure behind an error outcome or an
+// unexpected page.
+type errorClass int
+
+const (
+	errClassUnknown    errorClass = iota
+	errClassTimeout               // the page or a step took longer than allowed
+	errClassNavigation            // the browser could not load the page (connection refused, reset, ...)
+	errClassDNS                   // the host name did not resolve
+	errClassTLS                   // a certificate or handshake problem
+	errClassCancelled             // the browser or check was cancelled
+	errClassServer                // an HTTP 5xx response
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case errClassTimeout:
+		return "timeout"
+	case errClassNavigation:
+		return "navigation"
+	case errClassDNS:
+		return "dns"
+	case errClassTLS:
+		return "tls"
+	case errClassCancelled:
+		return "cancelled"
+	case errClassServer:
+		return "http_5xx"
+	default:
+		return "unknown"
+	}
+}
+
+// classifyError returns the class of a failed check from the load error, or
+// from the page's HTTP status when the page did load. Chrome reports network
+// failures as "net::ERR_..." codes in the navigation error.
+func classifyError(err error, status int64) errorClass {
+	if err == nil {
+		if status >= 500 {
+			return errClassServer
+		}
+		return errClassUnknown
+	}
+	var dnsErr *net.DNSError
+	msg := err.Error()
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errClassCancelled
+	case errors.Is(err, context.DeadlineExceeded), strings.Contains(msg, "ERR_TIMED_OUT"), strings.Contains(msg, "ERR_CONNECTION_TIMED_OUT"):
+		return errClassTimeout
+	case errors.As(err, &dnsErr), strings.Contains(msg, "ERR_NAME_NOT_RESOLVED"), strings.Contains(msg, "ERR_NAME_RESOLUTION_FAILED"):
+		return errClassDNS
+	case strings.Contains(msg, "ERR_CERT_"), strings.Contains(msg, "ERR_SSL_"), strings.Contains(msg, "tls:"), strings.Contains(msg, "x509:"):
+		return errClassTLS
+	case strings.Contains(msg, "net::ERR_"), strings.Contains(msg, "page load error"):
+		return errClassNavigation
+	default:
+		return errClassUnknown
+	}
+}
+
+// onError advances the backoff for a failure of class c and returns the
+// delay. DNS and TLS failures rarely fix themselves within minutes, so they
+// back off twice as fast; a 5xx is usually a brief outage, so it keeps the
+// current delay instead of growing it.
+func (b *backoffState) onError(c errorClass) time.Duration {
+	switch c {
+	case errClassDNS, errClassTLS:
+		b.onFailure()
+		return b.onFailure()
+	case errClassServer:
+		return b.current
+	default:
+		return b.onFailure()
+	}
+}
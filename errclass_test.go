@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int64
+		want   errorClass
+	}{
+		{nil, 200, errClassUnknown},
+		{nil, 502, errClassServer},
+		{context.Canceled, 0, errClassCancelled},
+		{fmt.Errorf("check: %w", context.DeadlineExceeded), 0, errClassTimeout},
+		{errors.New("page load error net::ERR_CONNECTION_TIMED_OUT"), 0, errClassTimeout},
+		{&net.DNSError{Err: "no such host", Name: "service.berlin.de"}, 0, errClassDNS},
+		{errors.New("page load error net::ERR_NAME_NOT_RESOLVED"), 0, errClassDNS},
+		{errors.New("page load error net::ERR_CERT_DATE_INVALID"), 0, errClassTLS},
+		{errors.New("page load error net::ERR_SSL_PROTOCOL_ERROR"), 0, errClassTLS},
+		{errors.New("page load error net::ERR_CONNECTION_REFUSED"), 0, errClassNavigation},
+		{errors.New("could not find node"), 0, errClassUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err, tt.status); got != tt.want {
+			t.Errorf("classifyError(%v, %d) = %s, want %s", tt.err, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffOnError(t *testing.T) {
+	b := newBackoffState(time.Minute, time.Hour)
+	if got := b.onError(errClassNavigation); got != 2*time.Minute {
+		t.Errorf("navigation: got %s, want 2m", got)
+	}
+	if got := b.onError(errClassServer); got != 2*time.Minute {
+		t.Errorf("5xx: got %s, want the delay kept at 2m", got)
+	}
+	if got := b.onError(errClassDNS); got != 8*time.Minute {
+		t.Errorf("dns: got %s, want 8m", got)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+)
+
+// maxGapSamples bounds how many sightings gapStats keeps, making its
+// statistics rolling rather than all-time.
+const maxGapSamples = 50
+
+// gapStats tracks when distinct appointment sightings happened (those the
+// throttle let through) to summarize the time between them.
+type gapStats struct {
+	times []time.Time // oldest first
+}
+
+// add records a sighting at t.
+func (g *gapStats) add(t time.Time) {
+	g.times = append(g.times, t)
+	if len(g.times) > maxGapSamples {
+		g.times = g.times[len(g.times)-maxGapSamples:]
+	}
+}
+
+// summary returns the number of gaps between the recorded sightings and
+// their mean and minimum. All are zero with fewer than two sightings.
+func (g *gapStats) summary() (n int, mean, min time.Duration) {
+	if len(g.times) < 2 {
+		return 0, 0, 0
+	}
+	var total time.Duration
+	for i := 1; i < len(g.times); i++ {
+		gap := g.times[i].Sub(g.times[i-1])
+		total += gap
+		if i == 1 || gap < min {
+			min = gap
+		}
+	}
+	n = len(g.times) - 1
+	return n, total / time.Duration(n), min
+}
+
+// load restores the sightings from path. A missing file is not an error.
+func (g *gapStats) load(path string) error {
+	var st struct {
+		Sightings []time.Time `json:"sightings"`
+	}
+	if err := loadJSON(path, &st); err != nil {
+		return err
+	}
+	g.times = st.Sightings
+	return nil
+}
+
+// save writes the sightings to path, replacing it atomically.
+func (g *gapStats) save(path string) error {
+	return saveJSON(path, struct {
+		Sightings []time.Time `json:"sightings"`
+	}{g.times})
+}
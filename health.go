@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// health tracks when each service's check loop last completed an iteration
+// so a probe can tell whether one is stuck. Each service is judged by its own
+// wait, so a slow service isn't reported stuck by a fast one's standard and
+// a fast one can't hide a stuck one. A nil *health is valid and records
+// nothing.
+type health struct {
+	mu       sync.Mutex
+	grace    time.Duration          // allowed on top of the waits: one check's duration
+	services []string               // in config order
+	loops    map[string]*healthLoop // by service name; "" without services
+}
+
+type healthLoop struct {
+	last   time.Time     // when the last iteration completed
+	wait   time.Duration // scheduled after it
+	paused bool          // held by the --tui pause; not judged
+}
+
+// newHealth returns a health tracker that treats every service as fresh at
+// start for its first wait, its effective interval with def as --interval.
+// grace is how long a check may take (--check-timeout), so a short interval
+// doesn't make a slow but working check look stuck.
+func newHealth(services []*Config, def, grace time.Duration) *health {
+	h := &health{grace: grace, loops: make(map[string]*healthLoop)}
+	now := time.Now()
+	for _, c := range services {
+		h.services = append(h.services, c.name)
+		h.loops[c.name] = &healthLoop{last: now, wait: c.checkInterval(def)}
+	}
+	return h
+}
+
+// checked records a completed iteration of service followed by a wait of next.
+func (h *health) checked(service string, next time.Duration) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.loops[service]
+	if !ok {
+		h.services = append(h.services, service)
+		l = &healthLoop{}
+		h.loops[service] = l
+	}
+	l.last, l.wait, l.paused = time.Now(), next, false
+}
+
+// pause excludes service from the check while --tui holds it, for however
+// long that is.
+func (h *health) pause(service string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if l, ok := h.loops[service]; ok {
+		l.paused = true
+	}
+}
+
+// resume judges service again, counting from now so the paused time
+// doesn't count against it.
+func (h *health) resume(service string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if l, ok := h.loops[service]; ok && l.paused {
+		l.paused = false
+		l.last = time.Now()
+	}
+}
+
+// status reports the first service whose last iteration finished more than
+// three of its waits plus the grace before now, or ok.
+func (h *health) status(now time.Time) (ok bool, msg string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var oldest time.Duration
+	for _, svc := range h.services {
+		l := h.loops[svc]
+		if l.paused {
+			continue
+		}
+		since, limit := now.Sub(l.last), 3*l.wait+h.grace
+		if since > limit {
+			name := ""
+			if svc != "" {
+				name = svc + ": "
+			}
+			return false, fmt.Sprintf("stuck: %slast check %s ago (limit %s)", name, since.Round(time.Second), limit)
+		}
+		oldest = max(oldest, since)
+	}
+	return true, fmt.Sprintf("ok: last check %s ago", oldest.Round(time.Second))
+}
+
+// ServeHTTP answers 200 while every service's last check finished less than
+// three of its waits (plus the grace) ago, and 503 otherwise.
+func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ok, msg := h.status(time.Now())
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintln(w, msg)
+}
+
+// serveHealth exposes h on addr at /healthz until ctx is cancelled.
+func serveHealth(ctx context.Context, addr string, h *health) {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", h)
+	serve(ctx, "health", addr, mux)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthPerService(t *testing.T) {
+	cfg := &Config{Services: []ServiceConfig{
+		{Name: "fast", ServiceURL: defaultServiceURL, Interval: time.Minute},
+		{Name: "slow", ServiceURL: defaultServiceURL, Interval: 10 * time.Minute},
+	}}
+	cfg.validate()
+	h := newHealth(cfg.forServices(), 3*time.Minute, 0)
+	start := time.Now()
+
+	if ok, msg := h.status(start.Add(20 * time.Minute)); ok || !strings.Contains(msg, "fast") {
+		t.Errorf("fast service silent for 20m: %v %q, want it stuck", ok, msg)
+	}
+	h.checked("fast", 20*time.Minute) // e.g. backing off
+	if ok, msg := h.status(start.Add(20 * time.Minute)); !ok {
+		t.Errorf("slow service within three of its own intervals: %q, want ok", msg)
+	}
+	if ok, msg := h.status(start.Add(31 * time.Minute)); ok || !strings.Contains(msg, "slow") {
+		t.Errorf("slow service silent for 31m: %v %q, want it stuck despite the fast one", ok, msg)
+	}
+}
+
+func TestHealthPause(t *testing.T) {
+	h := newHealth([]*Config{{name: "a"}, {name: "b"}}, time.Minute, 0)
+	h.pause("a")
+	h.checked("b", time.Hour)
+	if ok, msg := h.status(time.Now().Add(10 * time.Minute)); !ok {
+		t.Errorf("paused service judged: %q", msg)
+	}
+	h.resume("a")
+	if ok, msg := h.status(time.Now().Add(2 * time.Minute)); !ok {
+		t.Errorf("paused time counted after resume: %q", msg)
+	}
+	if ok, _ := h.status(time.Now().Add(4 * time.Minute)); ok {
+		t.Error("resumed service not judged again")
+	}
+}
+
+func TestHealthAllowsForSlowChecks(t *testing.T) {
+	cfg := &Config{Interval: 5 * time.Second}
+	h := newHealth([]*Config{cfg}, time.Minute, 45*time.Second)
+	h.checked("", 5*time.Second)
+	// The next check starts after 5s and takes 40s, well within --check-timeout.
+	if ok, msg := h.status(time.Now().Add(45 * time.Second)); !ok {
+		t.Errorf("5s interval with a 40s check: %q, want ok", msg)
+	}
+	if ok, _ := h.status(time.Now().Add(2 * time.Minute)); ok {
+		t.Error("silent for 2m with a 5s interval and 45s check timeout: want stuck")
+	}
+}
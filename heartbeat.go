@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat periodically posts a sign of life with the number of checks since
+// the previous one, so a silently dead process gets noticed. A nil
+// *heartbeat is valid and records nothing.
+type heartbeat struct {
+	dryRun     bool
+	changed    chan struct{} // wakes run when update changes the interval
+	checks     atomic.Int64  // since the last heartbeat
+	lastStatus atomic.Int64
+
+	mu       sync.Mutex // guards the fields below, replaced by update
+	n        Notifier
+	prefix   string // marks heartbeats sent to the main webhook
+	interval time.Duration
+}
+
+// newHeartbeat returns nil when no interval is set or there is nowhere to send
+// to. Without heartbeat_webhook_url, heartbeats go to the main webhooks.
+func newHeartbeat(cfg *Config, interval time.Duration, dryRun bool) *heartbeat {
+	if interval <= 0 {
+		return nil
+	}
+	hb := &heartbeat{interval: interval, dryRun: dryRun, changed: make(chan struct{}, 1)}
+	if cfg.HeartbeatWebhookURL != "" {
+		hb.n = cfg.plainWebhook(cfg.HeartbeatWebhookURL)
+	} else if n := newWebhookNotifier(cfg); n != nil {
+		hb.n = n
+		hb.prefix = "[heartbeat] "
+	} else {
+		log.Printf("heartbeat: no heartbeat_webhook_url or webhook configured — heartbeat disabled")
+		return nil
+	}
+	if dryRun {
+		hb.n = dryRunNotifier{hb.n}
+	}
+	return hb
+}
+
+// checked counts a completed check and its HTTP status (0 if none).
+func (hb *heartbeat) checked(status int64) {
+	if hb == nil {
+		return
+	}
+	hb.checks.Add(1)
+	if status != 0 {
+		hb.lastStatus.Store(status)
+	}
+}
+
+// update switches hb to the heartbeat settings of a reloaded cfg: where it
+// posts and how often. Turning the heartbeat on or off needs a restart.
+func (hb *heartbeat) update(cfg *Config) {
+	if hb == nil {
+		if cfg.HeartbeatInterval > 0 {
+			log.Printf("config: reload: heartbeat_interval takes effect after a restart")
+		}
+		return
+	}
+	next := newHeartbeat(cfg, cfg.HeartbeatInterval, hb.dryRun)
+	if next == nil {
+		log.Printf("config: reload: the heartbeat stops after a restart — keeping it")
+		return
+	}
+	hb.mu.Lock()
+	changed := next.interval != hb.interval
+	hb.n, hb.prefix, hb.interval = next.n, next.prefix, next.interval
+	hb.mu.Unlock()
+	if changed {
+		select {
+		case hb.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// settings returns where to post and how often, as of the latest update.
+func (hb *heartbeat) settings() (n Notifier, prefix string, interval time.Duration) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	return hb.n, hb.prefix, hb.interval
+}
+
+// run sends a heartbeat every interval until ctx is cancelled.
+func (hb *heartbeat) run(ctx context.Context) {
+	_, _, interval := hb.settings()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hb.changed:
+			_, _, interval := hb.settings()
+			t.Reset(interval)
+			continue
+		case <-t.C:
+		}
+		n, prefix, _ := hb.settings()
+		msg := fmt.Sprintf("%sterminator alive, last status=%d, %d checks since last heartbeat",
+			prefix, hb.lastStatus.Load(), hb.checks.Swap(0))
+		if err := n.Notify(ctx, msg); err != nil && ctx.Err() == nil {
+			log.Printf("heartbeat: %v", redactErr(err))
+		}
+	}
+}
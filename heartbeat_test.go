@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatUpdate(t *testing.T) {
+	got := make(chan string, 10)
+	receiver := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			got <- name + ": " + string(body)
+		}))
+	}
+	old, next := receiver("old"), receiver("new")
+	defer old.Close()
+	defer next.Close()
+
+	hb := newHeartbeat(&Config{HeartbeatWebhookURL: old.URL}, time.Hour, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hb.run(ctx)
+
+	hb.update(&Config{HeartbeatWebhookURL: next.URL, HeartbeatInterval: 10 * time.Millisecond})
+	select {
+	case msg := <-got:
+		if msg[:4] != "new:" {
+			t.Errorf("heartbeat after reload went to %q, want the new heartbeat_webhook_url", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no heartbeat at the reloaded interval")
+	}
+
+	hb.update(&Config{HeartbeatWebhookURL: next.URL})
+	if _, _, interval := hb.settings(); interval != 10*time.Millisecond {
+		t.Errorf("interval %s after a reload without heartbeat_interval, want it kept until restart", interval)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const historySchema = `CREATE TABLE IF NOT EXISTS appointments (
+	id       INTEGER PRIMARY KEY,
+	found_at TEXT NOT NULL,    -- RFC 3339, UTC
+	service  TEXT NOT NULL,    -- empty for the single top-level service
+	status   INTEGER NOT NULL, -- HTTP status of the calendar page
+	url      TEXT NOT NULL,
+	days     TEXT NOT NULL     -- bookable days, comma-separated YYYY-MM-DD (Berlin); empty if unparsed
+)`
+
+// history records every successful check in a SQLite database, so it can be
+// queried later for when appointments tend to appear. A nil *history is valid
+// and does nothing.
+type history struct {
+	db *sql.DB
+}
+
+// openHistory opens or creates the database at path and its schema.
+func openHistory(path string) (*history, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// One connection serializes writes from concurrent services instead of
+	// failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &history{db: db}, nil
+}
+
+// record stores one successful check.
+func (h *history) record(at time.Time, service string, status int64, url string, days []time.Time) error {
+	if h == nil {
+		return nil
+	}
+	_, err := h.db.Exec(`INSERT INTO appointments (found_at, service, status, url, days) VALUES (?, ?, ?, ?, ?)`,
+		at.UTC().Format(time.RFC3339), service, status, url, strings.Join(formatDates(days), ","))
+	return err
+}
+
+func (h *history) Close() error {
+	if h == nil {
+		return nil
+	}
+	return h.db.Close()
+}
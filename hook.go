@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// runSuccessCommand runs on_success_command after a success notification,
+// with the service URL as its last argument and in TERMINATOR_URL, and the
+// message in TERMINATOR_MESSAGE. It waits for the command, killing it after
+// on_success_timeout so a hung command can't stall the check loop.
+func (s *sniper) runSuccessCommand(ctx context.Context, message string) {
+	cfg := s.cfg
+	if len(cfg.OnSuccessCommand) == 0 {
+		return
+	}
+	if s.dryRun {
+		s.logf("dry-run: would run on_success_command %s", cfg.OnSuccessCommand[0])
+		return
+	}
+	cmdCtx, cancel := context.WithTimeout(ctx, cfg.OnSuccessTimeout)
+	defer cancel()
+	args := append(slices.Clone(cfg.OnSuccessCommand[1:]), cfg.ServiceURL)
+	cmd := exec.CommandContext(cmdCtx, cfg.OnSuccessCommand[0], args...)
+	cmd.Env = append(os.Environ(), "TERMINATOR_URL="+cfg.ServiceURL, "TERMINATOR_MESSAGE="+message)
+	start := time.Now()
+	err := cmd.Run()
+	took := time.Since(start).Round(time.Millisecond).String()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		s.log.Info("on_success_command finished", "exit_status", 0, "took", took)
+	case errors.Is(cmdCtx.Err(), context.DeadlineExceeded):
+		s.log.Warn("on_success_command timed out and was killed", "timeout", cfg.OnSuccessTimeout.String())
+	case errors.As(err, &exitErr):
+		s.log.Warn("on_success_command failed", "exit_status", exitErr.ExitCode(), "took", took)
+	default:
+		s.log.Warn("on_success_command could not run", "err", err)
+	}
+}
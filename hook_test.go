@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunSuccessCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	s := &sniper{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg: &Config{
+			ServiceURL:       "https://service.berlin.de/dienstleistung/120686/",
+			OnSuccessCommand: []string{"sh", "-c", `printf '%s %s' "$1" "$TERMINATOR_MESSAGE" > ` + out, "sh"},
+			OnSuccessTimeout: 5 * time.Second,
+		},
+	}
+	s.runSuccessCommand(context.Background(), "found")
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://service.berlin.de/dienstleistung/120686/ found"; string(got) != want {
+		t.Errorf("command saw %q, want %q", got, want)
+	}
+}
+
+func TestRunSuccessCommandTimeout(t *testing.T) {
+	s := &sniper{
+		log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		cfg: &Config{OnSuccessCommand: []string{"sh", "-c", "sleep 10", "sh"}, OnSuccessTimeout: 50 * time.Millisecond},
+	}
+	start := time.Now()
+	s.runSuccessCommand(context.Background(), "found")
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("hung command blocked for %s", took)
+	}
+}
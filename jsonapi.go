@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// apiBodyLimit caps how much of an availability_url response is read.
+const apiBodyLimit = 4 << 20
+
+// useAPI reports whether this check can skip the browser and ask
+// availability_url directly: cookies from a full render are at hand and
+// fewer than s.renderEvery checks have used them.
+func (s *sniper) useAPI() bool {
+	return s.cfg.AvailabilityURL != "" && s.apiCookies != nil && s.apiChecks < s.renderEvery-1
+}
+
+// saveAPICookies keeps the browser's cookies for availability_url after a
+// full render, for the lightweight checks that follow.
+func (s *sniper) saveAPICookies(ctx context.Context) {
+	var cookies []*network.Cookie
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{s.cfg.AvailabilityURL}).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		s.logf("api: could not read cookies (%v) — next check renders the page again", err)
+		s.apiCookies = nil
+		return
+	}
+	s.apiCookies = make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		s.apiCookies = append(s.apiCookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	s.apiChecks = 0
+}
+
+// loadAPI fetches availability_url with net/http, carrying the browser's
+// cookies and User-Agent, and turns the answer into a pageState: the value
+// at availability_path decides whether it reads as the first success body id
+// or as the taken page, so classify treats it like a rendered page. Any
+// failure drops the cookies, so the next check renders the page again.
+func (s *sniper) loadAPI(ctx context.Context) (p pageState, err error) {
+	cfg := s.cfg
+	s.apiChecks++
+	span := s.span.child("fetch availability_url")
+	defer func() {
+		if err != nil {
+			s.apiCookies = nil
+		}
+		span.set("http.status", p.status)
+		span.fail(err)
+		span.finish()
+	}()
+	ctx, cancel := context.WithTimeout(ctx, s.checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.AvailabilityURL, nil)
+	if err != nil {
+		return pageState{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Referer", cfg.ServiceURL)
+	req.Header.Set("User-Agent", s.userAgent)
+	for _, c := range s.apiCookies {
+		req.AddCookie(c)
+	}
+	client := http.DefaultClient
+	if s.proxy != nil {
+		client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(s.proxy)}}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return pageState{}, err
+	}
+	defer resp.Body.Close()
+	s.log.Debug("response", "type", "api", "status", resp.StatusCode, "mime", resp.Header.Get("Content-Type"), "url", cfg.AvailabilityURL)
+
+	p = pageState{status: int64(resp.StatusCode), url: cfg.AvailabilityURL, api: true}
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		p.retryAfter, _ = parseRetryAfter(ra, s.clock.Now())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Let classify see rate limiting and server errors as usual.
+		return p, nil
+	}
+	var doc any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, apiBodyLimit)).Decode(&doc); err != nil {
+		return pageState{}, fmt.Errorf("availability_url: %w", err)
+	}
+	v, ok := jsonPath(doc, cfg.AvailabilityPath)
+	if !ok {
+		return pageState{}, fmt.Errorf("availability_url: %q not found in the response", cfg.AvailabilityPath)
+	}
+	p.bodyID = cfg.TakenBodyID
+	if jsonAvailable(v) {
+		p.bodyID = cfg.SuccessBodyIDs[0]
+	}
+	return p, nil
+}
+
+// jsonPath follows a dotted path ("data.slots", "days.0") into a decoded JSON
+// document. An empty path is the document itself.
+func jsonPath(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch t := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = t[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// jsonAvailable reports whether a JSON value means slots are available: true,
+// a positive number, a non-empty array, object or string.
+func jsonAvailable(v any) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case float64:
+		return t > 0
+	case string:
+		return t != ""
+	case []any:
+		return len(t) > 0
+	case map[string]any:
+		return len(t) > 0
+	default:
+		return false
+	}
+}
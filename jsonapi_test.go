@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJSONAvailability(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{"data":{"slots":[],"days":[{"free":3}],"open":false,"note":""}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path      string
+		found     bool
+		available bool
+	}{
+		{"data.slots", true, false},
+		{"data.days", true, true},
+		{"data.days.0.free", true, true},
+		{"data.open", true, false},
+		{"data.note", true, false},
+		{"data.days.1", false, false},
+		{"data.missing", false, false},
+		{"data.open.x", false, false},
+	} {
+		v, ok := jsonPath(doc, tc.path)
+		if ok != tc.found || jsonAvailable(v) != tc.available {
+			t.Errorf("%s: found=%v available=%v, want %v %v", tc.path, ok, jsonAvailable(v), tc.found, tc.available)
+		}
+	}
+}
+
+func TestLoadAPI(t *testing.T) {
+	body := `{"slots":[]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("Zmsappointment"); err != nil || c.Value != "abc" {
+			http.Error(w, "no session", http.StatusForbidden)
+			return
+		}
+		if r.UserAgent() != "test-agent" {
+			http.Error(w, "wrong agent", http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	s := &sniper{
+		log:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		clock:        realClock{},
+		checkTimeout: 5 * time.Second,
+		renderEvery:  10,
+		userAgent:    "test-agent",
+		apiCookies:   []*http.Cookie{{Name: "Zmsappointment", Value: "abc"}},
+		cfg: &Config{
+			AvailabilityURL:  srv.URL,
+			AvailabilityPath: "slots",
+			TakenBodyID:      "taken",
+			SuccessBodyIDs:   []string{"open"},
+		},
+	}
+	if !s.useAPI() {
+		t.Fatal("useAPI() = false with fresh cookies")
+	}
+	p, err := s.loadAPI(context.Background())
+	if err != nil || p.bodyID != "taken" || p.status != 200 {
+		t.Fatalf("no slots: got %+v, %v", p, err)
+	}
+	body = `{"slots":["2026-11-02"]}`
+	if p, err = s.loadAPI(context.Background()); err != nil || p.bodyID != "open" {
+		t.Fatalf("slots: got %+v, %v", p, err)
+	}
+
+	body = `<html>`
+	if _, err = s.loadAPI(context.Background()); err == nil {
+		t.Fatal("want an error for a non-JSON answer")
+	}
+	if s.apiCookies != nil || s.useAPI() {
+		t.Error("a failed API check should force a full render")
+	}
+}
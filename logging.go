@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// logger is used for the structured events emitted by the check loop. By
+// default it renders them as "msg key=value ..." through the standard log
+// package, matching the rest of the output. setupLogging switches it (and the
+// standard log package) to JSON lines.
+var logger = slog.New(&textHandler{})
+
+// logLevel is the minimum level logged: Info, or Debug with --debug.
+var logLevel = new(slog.LevelVar)
+
+func setupLogging(format string, debug bool) error {
+	if debug {
+		logLevel.Set(slog.LevelDebug)
+	}
+	switch format {
+	case "text":
+		return nil
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+		// Routes plain log.Printf calls through the JSON handler too.
+		slog.SetDefault(logger)
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+}
+
+// textHandler is a slog.Handler that writes human-readable lines via the
+// standard log package. A "service" attribute added with WithAttrs becomes a
+// "[name] " line prefix instead of a key=value pair.
+type textHandler struct {
+	prefix string
+	attrs  []slog.Attr
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString(h.prefix)
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("warning: ")
+	}
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		v := a.Value.Resolve().String()
+		if v == "" || strings.ContainsAny(v, " \"=") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&b, " %s=%s", a.Key, v)
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	log.Print(b.String())
+	return nil
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := &textHandler{prefix: h.prefix, attrs: h.attrs[:len(h.attrs):len(h.attrs)]}
+	for _, a := range attrs {
+		if a.Key == "service" {
+			h2.prefix = "[" + a.Value.String() + "] "
+			continue
+		}
+		h2.attrs = append(h2.attrs, a)
+	}
+	return h2
+}
+
+// WithGroup is not used by terminator; groups are flattened.
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestDebugLevel(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+	defer logLevel.Set(slog.LevelInfo)
+
+	s := &sniper{log: slog.New(&textHandler{})}
+	xhr := &network.EventResponseReceived{
+		Type:     network.ResourceTypeXHR,
+		Response: &network.Response{URL: "https://service.berlin.de/api", Status: 403, MimeType: "text/html"},
+	}
+	image := &network.EventResponseReceived{
+		Type:     network.ResourceTypeImage,
+		Response: &network.Response{URL: "https://service.berlin.de/logo.png", Status: 200},
+	}
+
+	s.logResponse(xhr)
+	if buf.Len() > 0 {
+		t.Fatalf("logged at normal verbosity: %s", buf.String())
+	}
+
+	if err := setupLogging("text", true); err != nil {
+		t.Fatal(err)
+	}
+	if !s.log.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug not enabled with --debug")
+	}
+	s.logResponse(xhr)
+	s.logResponse(image)
+	out := buf.String()
+	if !strings.Contains(out, "response type=xhr status=403 mime=text/html url=https://service.berlin.de/api") {
+		t.Errorf("got %q", out)
+	}
+	if strings.Contains(out, "logo.png") {
+		t.Error("images should not be logged")
+	}
+}
@@ -3,32 +3,300 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
 	"log"
+	"math/rand/v2"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"syscall"
+	"text/template"
 	"time"
+	_ "time/tzdata" // quiet hours need zone info on hosts without it
 
-	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	serviceURL = "https://service.berlin.de/dienstleistung/351180/"
+	defaultServiceURL = "https://service.berlin.de/dienstleistung/351180/"
+	defaultUserAgent  = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/145.0.0.0 Safari/537.36"
 )
 
 type Config struct {
-	WebhookURL string `yaml:"webhook_url"`
+	WebhookURL       string   `yaml:"webhook_url" secret:"true"`
+	WebhookURLs      []string `yaml:"webhook_urls" secret:"true"` // webhook_url is merged in by validate
+	TelegramBotToken string   `yaml:"telegram_bot_token" secret:"true"`
+	TelegramChatID   string   `yaml:"telegram_chat_id"`
+	DiscordWebhook   string   `yaml:"discord_webhook_url" secret:"true"`
+	SlackWebhook     string   `yaml:"slack_webhook_url" secret:"true"`
+	ProxyURL         string   `yaml:"proxy_url" secret:"true"` // validated at startup by parseProxy
+	Proxies          []string `yaml:"proxies" secret:"true"`   // rotated through after proxy_url; see sniper.nextProxy
+	BindAddress      string   `yaml:"bind_address"`            // source IP or interface for the browser's connections; see bindProxy
+	ErrorWebhookURL  string   `yaml:"error_webhook_url" secret:"true"`
+	ErrorThreshold   int      `yaml:"error_threshold"` // consecutive failed checks before error_webhook_url is called; default 5
+
+	// HeartbeatInterval, when set, posts a sign of life that often to
+	// HeartbeatWebhookURL, or to the main webhooks when that is empty.
+	HeartbeatInterval   time.Duration `yaml:"heartbeat_interval"`
+	HeartbeatWebhookURL string        `yaml:"heartbeat_webhook_url" secret:"true"`
+
+	// OnSuccessCommand is run (argv, no shell) after each success
+	// notification, with the service URL appended as the last argument. It
+	// is killed after OnSuccessTimeout (default 30s).
+	OnSuccessCommand []string      `yaml:"on_success_command"`
+	OnSuccessTimeout time.Duration `yaml:"on_success_timeout"`
+
+	// NotifierThrottles gives backends, by notifier name, their own
+	// throttle instead of the global one.
+	NotifierThrottles map[string]NotifierThrottle `yaml:"notifier_throttles"`
+
+	NtfyTopic     string `yaml:"ntfy_topic"`
+	NtfyServer    string `yaml:"ntfy_server"` // defaults to https://ntfy.sh
+	PushoverToken string `yaml:"pushover_token" secret:"true"`
+	PushoverUser  string `yaml:"pushover_user" secret:"true"`
+
+	// WhatsApp Cloud API settings; all three are required together.
+	// WhatsAppTo is the recipient's number in international format.
+	WhatsAppToken   string `yaml:"whatsapp_token" secret:"true"`
+	WhatsAppPhoneID string `yaml:"whatsapp_phone_id"`
+	WhatsAppTo      string `yaml:"whatsapp_to"`
+
+	// Gotify settings; both are required together.
+	GotifyURL   string `yaml:"gotify_url"`
+	GotifyToken string `yaml:"gotify_token" secret:"true"`
+
+	// Matrix settings; all three are required together.
+	MatrixHomeserver  string `yaml:"matrix_homeserver"`
+	MatrixAccessToken string `yaml:"matrix_access_token" secret:"true"`
+	MatrixRoomID      string `yaml:"matrix_room_id"`
+
+	// SMTP settings for email alerts. Host, port, from and to are required
+	// together; user and password are optional (no auth when empty).
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUser     string `yaml:"smtp_user"`
+	SMTPPassword string `yaml:"smtp_password" secret:"true"`
+	SMTPFrom     string `yaml:"smtp_from"`
+	SMTPTo       string `yaml:"smtp_to"` // comma-separated
+
+	// ServiceURL is the service page visited first to establish the session.
+	// AppointmentURL, when set, is navigated to directly afterwards; otherwise
+	// the Mitte booking link on the service page is clicked.
+	ServiceURL     string `yaml:"service_url"`
+	AppointmentURL string `yaml:"appointment_url"`
+
+	// Borough, when set and AppointmentURL isn't, books the service in all
+	// of the borough's locations; see boroughs. --borough overrides it.
+	Borough string `yaml:"borough"`
+
+	// AppointmentURLs are mirror endpoints of the booking page. When set,
+	// each check opens all of them in parallel tabs after the service page
+	// (instead of AppointmentURL) and succeeds if any one shows slots.
+	AppointmentURLs []string `yaml:"appointment_urls"`
+
+	WebhookContentType string `yaml:"webhook_content_type"`
+	WebhookTemplate    string `yaml:"webhook_template"`
+
+	// WebhookMethod is POST (default), PUT, PATCH or GET; GET sends the
+	// message as the "message" query parameter. WebhookHeaders are added to
+	// every webhook request, e.g. for Authorization.
+	WebhookMethod  string            `yaml:"webhook_method"`
+	WebhookHeaders map[string]string `yaml:"webhook_headers" secret:"true"`
+
+	// WebhookUsername and WebhookPassword, set together, add HTTP basic
+	// auth to every webhook request.
+	WebhookUsername string `yaml:"webhook_username"`
+	WebhookPassword string `yaml:"webhook_password" secret:"true"`
+
+	// MessageTemplate, when set, replaces the notification text. It is a
+	// text/template rendered with messageData.
+	MessageTemplate string `yaml:"message_template"`
+
+	// Quiet hours are "HH:MM" wall-clock times in QuietHoursTimezone during
+	// which notifications are suppressed. Start > end wraps past midnight.
+	QuietHoursStart    string `yaml:"quiet_hours_start"`
+	QuietHoursEnd      string `yaml:"quiet_hours_end"`
+	QuietHoursTimezone string `yaml:"quiet_hours_timezone"`
+
+	// Active hours are "HH:MM" Berlin times outside of which no checks run at
+	// all. Start > end wraps past midnight.
+	ActiveHoursStart string `yaml:"active_hours_start"`
+	ActiveHoursEnd   string `yaml:"active_hours_end"`
+
+	// Page markers used to classify a check. Empty values fall back to the
+	// service.berlin.de defaults. A page is a success when its body id is any
+	// of SuccessBodyIDs; success_body_id is merged in by validate.
+	SuccessBodyID       string   `yaml:"success_body_id"`
+	SuccessBodyIDs      []string `yaml:"success_body_ids"`
+	TakenBodyID         string   `yaml:"taken_body_id"`
+	MaintenanceHeadline string   `yaml:"maintenance_headline"`
+
+	// HeadlineSelectors (default h2, then h1) are tried in order on the
+	// booking page; the text of the first match that has any is the headline
+	// that maintenance_headline and the bot-challenge and block markers see.
+	HeadlineSelectors []string `yaml:"headline_selectors"`
+
+	// SuccessText adds conditions on the page text to the body id check.
+	SuccessText *SuccessText `yaml:"success_text"`
+
+	// PreSteps run on the booking page, in order, before it is classified.
+	PreSteps []PreStep `yaml:"pre_steps"`
+
+	// ConsentSelector is the accept button of the cookie-consent banner. When
+	// set, it is clicked if shown on the service page before clicking through
+	// and on the booking page before it is read.
+	ConsentSelector string `yaml:"consent_selector"`
+
+	// AvailabilityURL, when set, is a JSON endpoint the booking page gets its
+	// availability from. Between full renders (see --full-render-every),
+	// checks just fetch it with the browser's cookies and look at the value
+	// at AvailabilityPath, a dotted path such as "data.slots".
+	AvailabilityURL  string `yaml:"availability_url"`
+	AvailabilityPath string `yaml:"availability_path"`
+
+	// ReadySelector (default "body[id]") is waited for on the booking page,
+	// for at most ReadyTimeout (default 10s), before the page is read, so a
+	// slow render isn't mistaken for an unexpected page.
+	ReadySelector string        `yaml:"ready_selector"`
+	ReadyTimeout  time.Duration `yaml:"ready_timeout"`
+
+	// Headless defaults to true; --show-browser overrides it. WindowWidth and
+	// WindowHeight set the browser window size together; unset keeps Chrome's
+	// default.
+	Headless     *bool `yaml:"headless"`
+	WindowWidth  int   `yaml:"window_width"`
+	WindowHeight int   `yaml:"window_height"`
+
+	// UserAgents, when set, are picked from at random for each browser
+	// session instead of defaultUserAgent.
+	UserAgents []string `yaml:"user_agents"`
+
+	// CaptchaMarkers identify bot-challenge pages; see defaultCaptchaMarkers.
+	CaptchaMarkers []string `yaml:"captcha_markers"`
+
+	// BlockMarkers identify WAF block pages; see defaultBlockMarkers.
+	BlockMarkers []string `yaml:"block_markers"`
+
+	// Services, when set, replaces the single service above with several
+	// monitored concurrently. Unset fields inherit the top-level values.
+	Services []ServiceConfig `yaml:"services"`
+
+	// MinDate and MaxDate ("YYYY-MM-DD", Berlin time, inclusive) limit which
+	// available days trigger a notification.
+	MinDate string `yaml:"min_date"`
+	MaxDate string `yaml:"max_date"`
+
+	// Interval, when set, overrides --interval. Unlike the flag it can be
+	// changed without a restart by editing the file and sending SIGHUP.
+	Interval time.Duration `yaml:"interval"`
+
+	// Weight divides the interval, so a service with weight 2 is checked
+	// twice as often as one with weight 1 (the default). Mostly set per
+	// service; a service's own interval takes precedence.
+	Weight float64 `yaml:"weight"`
+
+	webhookTmpl *template.Template
+	messageTmpl *template.Template
+	quietStart  int // minutes since midnight; quietLoc is nil when disabled
+	quietEnd    int
+	quietLoc    *time.Location
+	activeStart int // minutes since midnight, Berlin time
+	activeEnd   int
+	activeOn    bool
+	dates       dateFilter // from min_date/max_date; within is set from the flag
+
+	webhookAttempts int      // set from --webhook-attempts
+	desktopNotify   bool     // set from --desktop-notify
+	openBrowser     bool     // set from --open-browser
+	bellCount       int      // set from --bell and --bell-count; 0 disables the bell
+	name            string   // service name; empty for the single top-level service
+	bindIP          net.IP   // resolved BindAddress
+	problems        []string // logged by validate, see problemf
+}
+
+// ServiceConfig describes one of several services to monitor.
+type ServiceConfig struct {
+	Name                string        `yaml:"name"`
+	ServiceURL          string        `yaml:"service_url"`
+	AppointmentURL      string        `yaml:"appointment_url"`
+	AppointmentURLs     []string      `yaml:"appointment_urls"`
+	Borough             string        `yaml:"borough"`
+	SuccessBodyID       string        `yaml:"success_body_id"`
+	SuccessBodyIDs      []string      `yaml:"success_body_ids"`
+	TakenBodyID         string        `yaml:"taken_body_id"`
+	MaintenanceHeadline string        `yaml:"maintenance_headline"`
+	HeadlineSelectors   []string      `yaml:"headline_selectors"` // replace the top-level headline_selectors
+	WebhookURL          string        `yaml:"webhook_url"`        // in addition to the top-level webhooks
+	PreSteps            []PreStep     `yaml:"pre_steps"`          // replace the top-level pre_steps
+	SuccessText         *SuccessText  `yaml:"success_text"`       // replaces the top-level success_text
+	Interval            time.Duration `yaml:"interval"`           // replaces the top-level interval
+	Weight              float64       `yaml:"weight"`             // relative check frequency; see Config.Weight
+}
+
+// webhookIsJSON reports whether the webhook expects a JSON body.
+func (c *Config) webhookIsJSON() bool {
+	mt, _, err := mime.ParseMediaType(c.WebhookContentType)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inQuietHours reports whether t falls within the configured quiet hours.
+func (c *Config) inQuietHours(t time.Time) bool {
+	if c.quietLoc == nil {
+		return false
+	}
+	t = t.In(c.quietLoc)
+	return inClockRange(t.Hour()*60+t.Minute(), c.quietStart, c.quietEnd)
+}
+
+// inClockRange reports whether minute-of-day m is in [start, end), wrapping
+// past midnight when start > end.
+func inClockRange(m, start, end int) bool {
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// untilActive returns how long from t until active hours next begin, or 0
+// when t is within them or no active hours are configured.
+func (c *Config) untilActive(t time.Time) time.Duration {
+	if !c.activeOn {
+		return 0
+	}
+	t = t.In(berlinLocation())
+	if inClockRange(t.Hour()*60+t.Minute(), c.activeStart, c.activeEnd) {
+		return 0
+	}
+	next := time.Date(t.Year(), t.Month(), t.Day(), c.activeStart/60, c.activeStart%60, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, c.activeStart/60, c.activeStart%60, 0, 0, t.Location())
+	}
+	return next.Sub(t)
+}
+
+func isHTTPURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
 }
 
 func loadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	data, err := readConfigFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -36,200 +304,1085 @@ func loadConfig(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
-	if u := cfg.WebhookURL; u != "" {
-		parsed, err := url.Parse(u)
-		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
-			log.Printf("config: webhook_url %q is not a valid http/https URL — webhook disabled", u)
-			cfg.WebhookURL = ""
+	applyEnv(&cfg, os.LookupEnv)
+	cfg.validate()
+	return &cfg, nil
+}
+
+// validate fills in defaults and disables anything that is misconfigured,
+// logging why. It is also used on an empty Config when no file is loaded.
+func (cfg *Config) validate() {
+	if u := cfg.ServiceURL; u == "" {
+		cfg.ServiceURL = defaultServiceURL
+	} else if !isHTTPURL(u) {
+		cfg.problemf("service_url %q is not a valid http/https URL — using %s", u, defaultServiceURL)
+		cfg.ServiceURL = defaultServiceURL
+	}
+	if u := cfg.AppointmentURL; u != "" && !isHTTPURL(u) {
+		cfg.problemf("appointment_url %q is not a valid http/https URL — clicking through to Mitte instead", u)
+		cfg.AppointmentURL = ""
+	}
+	if u := cfg.AvailabilityURL; u != "" && !isHTTPURL(u) {
+		cfg.problemf("availability_url %q is not a valid http/https URL — rendering every check", u)
+		cfg.AvailabilityURL = ""
+	}
+	var mirrors []string
+	for _, u := range cfg.AppointmentURLs {
+		if !isHTTPURL(u) {
+			cfg.problemf("appointment_urls entry %q is not a valid http/https URL — dropped", u)
+			continue
 		}
+		mirrors = append(mirrors, u)
+	}
+	cfg.AppointmentURLs = mirrors
+	if b := cfg.BindAddress; b != "" {
+		ip, err := resolveBindAddress(b)
+		if err != nil {
+			cfg.problemf("bind_address: %v — using the default route", err)
+			cfg.BindAddress = ""
+		}
+		cfg.bindIP = ip
+	}
+	if b := cfg.Borough; b != "" && boroughs[strings.ToLower(b)] == nil {
+		cfg.problemf("borough %q is unknown (known: %s) — clicking through to Mitte instead", b, knownBoroughs())
+		cfg.Borough = ""
+	}
+	candidates := cfg.WebhookURLs
+	if cfg.WebhookURL != "" {
+		candidates = append([]string{cfg.WebhookURL}, candidates...)
+	}
+	cfg.WebhookURLs = nil
+	for _, u := range candidates {
+		if !isHTTPURL(u) {
+			cfg.problemf("webhook URL %q is not a valid http/https URL — dropped", redactURL(u))
+			continue
+		}
+		if slices.Contains(cfg.WebhookURLs, u) {
+			continue
+		}
+		cfg.WebhookURLs = append(cfg.WebhookURLs, u)
+	}
+	if cfg.WebhookContentType == "" {
+		cfg.WebhookContentType = "text/plain"
+	}
+	if _, _, err := mime.ParseMediaType(cfg.WebhookContentType); err != nil {
+		cfg.problemf("webhook_content_type %q is invalid (%v) — using text/plain", cfg.WebhookContentType, err)
+		cfg.WebhookContentType = "text/plain"
+	}
+	if t := cfg.WebhookTemplate; t != "" && cfg.webhookIsJSON() {
+		tmpl, err := template.New("webhook").Parse(t)
+		if err != nil {
+			cfg.problemf("webhook_template does not parse (%v) — using default JSON body", err)
+		} else {
+			cfg.webhookTmpl = tmpl
+		}
+	}
+	switch m := strings.ToUpper(strings.TrimSpace(cfg.WebhookMethod)); m {
+	case "":
+		cfg.WebhookMethod = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodGet:
+		cfg.WebhookMethod = m
+	default:
+		cfg.problemf("webhook_method %q is not one of POST, PUT, PATCH, GET — using POST", cfg.WebhookMethod)
+		cfg.WebhookMethod = http.MethodPost
+	}
+	if (cfg.WebhookUsername == "") != (cfg.WebhookPassword == "") {
+		cfg.problemf("webhook_username and webhook_password must both be set — basic auth disabled")
+		cfg.WebhookUsername, cfg.WebhookPassword = "", ""
+	}
+	if t := cfg.MessageTemplate; t != "" {
+		tmpl, err := template.New("message").Parse(t)
+		if err != nil {
+			cfg.problemf("message_template does not parse (%v) — using the default message", err)
+		} else {
+			cfg.messageTmpl = tmpl
+		}
+	}
+	if u := cfg.DiscordWebhook; u != "" && !isDiscordWebhook(u) {
+		cfg.problemf("discord_webhook_url %q is not a Discord webhook URL — discord disabled", redactURL(u))
+		cfg.DiscordWebhook = ""
+	}
+	if u := cfg.SlackWebhook; u != "" && !isSlackWebhook(u) {
+		cfg.problemf("slack_webhook_url %q is not a Slack webhook URL — slack disabled", redactURL(u))
+		cfg.SlackWebhook = ""
+	}
+	cfg.validateSMTP()
+	if u := cfg.ErrorWebhookURL; u != "" && !isHTTPURL(u) {
+		cfg.problemf("error_webhook_url %q is not a valid http/https URL — error alerts disabled", redactURL(u))
+		cfg.ErrorWebhookURL = ""
+	}
+	if u := cfg.HeartbeatWebhookURL; u != "" && !isHTTPURL(u) {
+		cfg.problemf("heartbeat_webhook_url %q is not a valid http/https URL — using the main webhooks", redactURL(u))
+		cfg.HeartbeatWebhookURL = ""
+	}
+	if cfg.ErrorThreshold <= 0 {
+		cfg.ErrorThreshold = 5
+	}
+	if cfg.NtfyServer == "" {
+		cfg.NtfyServer = "https://ntfy.sh"
+	}
+	if !isHTTPURL(cfg.NtfyServer) {
+		if cfg.NtfyTopic != "" {
+			cfg.problemf("ntfy_server %q is not a valid http/https URL — ntfy disabled", cfg.NtfyServer)
+		}
+		cfg.NtfyTopic = ""
+	}
+	if t := cfg.NtfyTopic; strings.ContainsAny(t, "/ ") {
+		cfg.problemf("ntfy_topic %q must not contain slashes or spaces — ntfy disabled", t)
+		cfg.NtfyTopic = ""
+	}
+	if cfg.QuietHoursStart != "" || cfg.QuietHoursEnd != "" {
+		cfg.validateQuietHours()
+	}
+	if cfg.ActiveHoursStart != "" || cfg.ActiveHoursEnd != "" {
+		cfg.validateActiveHours()
+	}
+	cfg.validateDates()
+	if p := validatePreSteps(cfg.PreSteps); p != "" {
+		cfg.problemf("pre_steps: %s — pre_steps disabled", p)
+		cfg.PreSteps = nil
+	}
+	if cfg.SuccessText.active() {
+		if p := cfg.SuccessText.validate(); p != "" {
+			cfg.problemf("success_text: %s — success_text disabled", p)
+			cfg.SuccessText = nil
+		}
+	}
+	if cfg.Weight < 0 {
+		cfg.problemf("weight %g is negative — using 1", cfg.Weight)
+		cfg.Weight = 0
+	}
+	cfg.validateServices()
+	cfg.SuccessBodyIDs = bodyIDs(cfg.SuccessBodyID, cfg.SuccessBodyIDs)
+	if len(cfg.SuccessBodyIDs) == 0 {
+		// The day calendar, and the time selection page a deep link may open.
+		cfg.SuccessBodyIDs = []string{"dayselect", "timeselect"}
+	}
+	if cfg.TakenBodyID == "" {
+		cfg.TakenBodyID = "taken"
+	}
+	if cfg.MaintenanceHeadline == "" {
+		cfg.MaintenanceHeadline = "Wartung"
+	}
+	cfg.HeadlineSelectors = selectors(cfg.HeadlineSelectors)
+	if len(cfg.HeadlineSelectors) == 0 {
+		cfg.HeadlineSelectors = []string{"h2", "h1"}
+	}
+	if cfg.ReadySelector == "" {
+		cfg.ReadySelector = "body[id]"
+	}
+	if cfg.ReadyTimeout < 0 {
+		cfg.problemf("ready_timeout must not be negative, got %s — using 10s", cfg.ReadyTimeout)
+		cfg.ReadyTimeout = 0
+	}
+	if cfg.ReadyTimeout == 0 {
+		cfg.ReadyTimeout = 10 * time.Second
+	}
+	if len(cfg.OnSuccessCommand) > 0 && cfg.OnSuccessCommand[0] == "" {
+		cfg.problemf("on_success_command has an empty program name — on_success_command disabled")
+		cfg.OnSuccessCommand = nil
+	}
+	if cfg.OnSuccessTimeout < 0 {
+		cfg.problemf("on_success_timeout must not be negative, got %s — using 30s", cfg.OnSuccessTimeout)
+		cfg.OnSuccessTimeout = 0
+	}
+	if cfg.OnSuccessTimeout == 0 {
+		cfg.OnSuccessTimeout = 30 * time.Second
+	}
+	for name, t := range cfg.NotifierThrottles {
+		switch {
+		case !slices.Contains(notifierNames, name):
+			cfg.problemf("notifier_throttles: unknown notifier %q (one of %s) — ignored", name, strings.Join(notifierNames, ", "))
+			delete(cfg.NotifierThrottles, name)
+		case t.Window < 0 || t.Cooldown < 0:
+			cfg.problemf("notifier_throttles: %s window and cooldown must not be negative — using the global throttle", name)
+			delete(cfg.NotifierThrottles, name)
+		case !t.Always && t.Window == 0 && t.Cooldown == 0:
+			cfg.problemf("notifier_throttles: %s needs window, cooldown or always — using the global throttle", name)
+			delete(cfg.NotifierThrottles, name)
+		}
+	}
+	if cfg.WindowWidth != 0 || cfg.WindowHeight != 0 {
+		if cfg.WindowWidth <= 0 || cfg.WindowHeight <= 0 {
+			cfg.problemf("window_width and window_height must both be positive, got %dx%d — using the default window size", cfg.WindowWidth, cfg.WindowHeight)
+			cfg.WindowWidth, cfg.WindowHeight = 0, 0
+		}
+	}
+	cfg.UserAgents = slices.DeleteFunc(cfg.UserAgents, func(ua string) bool { return strings.TrimSpace(ua) == "" })
+	if len(cfg.CaptchaMarkers) == 0 {
+		cfg.CaptchaMarkers = defaultCaptchaMarkers
+	}
+	if len(cfg.BlockMarkers) == 0 {
+		cfg.BlockMarkers = defaultBlockMarkers
+	}
+	if (cfg.TelegramBotToken == "") != (cfg.TelegramChatID == "") {
+		cfg.problemf("telegram_bot_token and telegram_chat_id must both be set — telegram disabled")
+		cfg.TelegramBotToken = ""
+		cfg.TelegramChatID = ""
+	}
+	cfg.validateMatrix()
+	if (cfg.PushoverToken == "") != (cfg.PushoverUser == "") {
+		cfg.problemf("pushover_token and pushover_user must both be set — pushover disabled")
+		cfg.PushoverToken = ""
+		cfg.PushoverUser = ""
+	}
+	set := []bool{cfg.WhatsAppToken != "", cfg.WhatsAppPhoneID != "", cfg.WhatsAppTo != ""}
+	if slices.Contains(set, true) && slices.Contains(set, false) {
+		cfg.problemf("whatsapp_token, whatsapp_phone_id and whatsapp_to must all be set — whatsapp disabled")
+		cfg.WhatsAppToken = ""
+	}
+	switch {
+	case (cfg.GotifyURL == "") != (cfg.GotifyToken == ""):
+		cfg.problemf("gotify_url and gotify_token must both be set — gotify disabled")
+		cfg.GotifyURL = ""
+	case cfg.GotifyURL != "" && !isHTTPURL(cfg.GotifyURL):
+		cfg.problemf("gotify_url %q is not a valid http/https URL — gotify disabled", redactURL(cfg.GotifyURL))
+		cfg.GotifyURL = ""
 	}
-	return &cfg, nil
 }
 
-func callWebhook(webhookURL string) {
-	msg := "Found an Appointment, check " + serviceURL
-	resp, err := http.Post(webhookURL, "text/plain", strings.NewReader(msg))
+func (cfg *Config) validateMatrix() {
+	set := []bool{cfg.MatrixHomeserver != "", cfg.MatrixAccessToken != "", cfg.MatrixRoomID != ""}
+	if !slices.Contains(set, true) {
+		return
+	}
+	switch {
+	case slices.Contains(set, false):
+		cfg.problemf("matrix_homeserver, matrix_access_token and matrix_room_id must all be set — matrix disabled")
+	case !isHTTPURL(cfg.MatrixHomeserver):
+		cfg.problemf("matrix_homeserver %q is not a valid http/https URL — matrix disabled", cfg.MatrixHomeserver)
+	default:
+		return
+	}
+	cfg.MatrixRoomID = ""
+}
+
+func (cfg *Config) validateSMTP() {
+	set := []bool{cfg.SMTPHost != "", cfg.SMTPPort != 0, cfg.SMTPFrom != "", cfg.SMTPTo != ""}
+	if !slices.Contains(set, true) {
+		return
+	}
+	switch {
+	case slices.Contains(set, false):
+		cfg.problemf("smtp_host, smtp_port, smtp_from and smtp_to must all be set — email disabled")
+	case cfg.SMTPPort < 1 || cfg.SMTPPort > 65535:
+		cfg.problemf("smtp_port %d is out of range — email disabled", cfg.SMTPPort)
+	case (cfg.SMTPUser == "") != (cfg.SMTPPassword == ""):
+		cfg.problemf("smtp_user and smtp_password must be set together — email disabled")
+	default:
+		return
+	}
+	cfg.SMTPHost = ""
+}
+
+func (cfg *Config) validateQuietHours() {
+	tz := cfg.QuietHoursTimezone
+	if tz == "" {
+		tz = "Europe/Berlin"
+	}
+	start, err := parseClock(cfg.QuietHoursStart)
+	if err != nil {
+		cfg.problemf("quiet_hours_start %q is not HH:MM — quiet hours disabled", cfg.QuietHoursStart)
+		return
+	}
+	end, err := parseClock(cfg.QuietHoursEnd)
+	if err != nil {
+		cfg.problemf("quiet_hours_end %q is not HH:MM — quiet hours disabled", cfg.QuietHoursEnd)
+		return
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		cfg.problemf("quiet_hours_timezone %q is unknown (%v) — quiet hours disabled", tz, err)
+		return
+	}
+	if start == end {
+		cfg.problemf("quiet_hours_start and quiet_hours_end are equal — quiet hours disabled")
+		return
+	}
+	cfg.quietStart, cfg.quietEnd, cfg.quietLoc = start, end, loc
+}
+
+func (cfg *Config) validateActiveHours() {
+	start, err := parseClock(cfg.ActiveHoursStart)
 	if err != nil {
-		log.Printf("webhook: request failed: %v", err)
+		cfg.problemf("active_hours_start %q is not HH:MM — checking around the clock", cfg.ActiveHoursStart)
+		return
+	}
+	end, err := parseClock(cfg.ActiveHoursEnd)
+	if err != nil {
+		cfg.problemf("active_hours_end %q is not HH:MM — checking around the clock", cfg.ActiveHoursEnd)
+		return
+	}
+	if start == end {
+		cfg.problemf("active_hours_start and active_hours_end are equal — checking around the clock")
 		return
 	}
-	defer resp.Body.Close()
-	log.Printf("webhook: called %s → %d", webhookURL, resp.StatusCode)
+	cfg.activeStart, cfg.activeEnd, cfg.activeOn = start, end, true
+}
+
+func (cfg *Config) validateDates() {
+	berlin := berlinLocation()
+	if d := cfg.MinDate; d != "" {
+		if t, err := time.ParseInLocation("2006-01-02", d, berlin); err != nil {
+			cfg.problemf("min_date %q is not YYYY-MM-DD — ignored", d)
+		} else {
+			cfg.dates.from = t
+		}
+	}
+	if d := cfg.MaxDate; d != "" {
+		if t, err := time.ParseInLocation("2006-01-02", d, berlin); err != nil {
+			cfg.problemf("max_date %q is not YYYY-MM-DD — ignored", d)
+		} else {
+			cfg.dates.until = t.AddDate(0, 0, 1)
+		}
+	}
+}
+
+// validateServices drops services without a unique name or with invalid URLs.
+func (cfg *Config) validateServices() {
+	var valid []ServiceConfig
+	seen := make(map[string]bool)
+	for i, svc := range cfg.Services {
+		switch {
+		case svc.Name == "":
+			cfg.problemf("services[%d] has no name — dropped", i)
+		case seen[svc.Name]:
+			cfg.problemf("service %q is defined more than once — duplicate dropped", svc.Name)
+		case svc.ServiceURL == "" || !isHTTPURL(svc.ServiceURL):
+			cfg.problemf("service %q needs a valid http/https service_url — dropped", svc.Name)
+		case svc.AppointmentURL != "" && !isHTTPURL(svc.AppointmentURL):
+			cfg.problemf("service %q appointment_url %q is not a valid http/https URL — dropped", svc.Name, svc.AppointmentURL)
+		case slices.ContainsFunc(svc.AppointmentURLs, func(u string) bool { return !isHTTPURL(u) }):
+			cfg.problemf("service %q has an appointment_urls entry that is not a valid http/https URL — dropped", svc.Name)
+		default:
+			if p := validatePreSteps(svc.PreSteps); p != "" {
+				cfg.problemf("service %q pre_steps: %s — pre_steps disabled", svc.Name, p)
+				svc.PreSteps = nil
+			}
+			if svc.SuccessText.active() {
+				if p := svc.SuccessText.validate(); p != "" {
+					cfg.problemf("service %q success_text: %s — success_text disabled", svc.Name, p)
+					svc.SuccessText = nil
+				}
+			}
+			if svc.Weight < 0 {
+				cfg.problemf("service %q weight %g is negative — using 1", svc.Name, svc.Weight)
+				svc.Weight = 0
+			}
+			if svc.Interval > 0 && svc.Weight > 0 {
+				cfg.problemf("service %q sets both interval and weight — weight ignored", svc.Name)
+				svc.Weight = 0
+			}
+			if b := svc.Borough; b != "" && boroughs[strings.ToLower(b)] == nil {
+				cfg.problemf("service %q borough %q is unknown (known: %s) — clicking through to Mitte instead", svc.Name, b, knownBoroughs())
+				svc.Borough = ""
+			}
+			if u := svc.WebhookURL; u != "" && !isHTTPURL(u) {
+				cfg.problemf("service %q webhook_url %q is not a valid http/https URL — dropped", svc.Name, redactURL(u))
+				svc.WebhookURL = ""
+			}
+			seen[svc.Name] = true
+			valid = append(valid, svc)
+		}
+	}
+	cfg.Services = valid
 }
 
-// notifyThrottle suppresses repeated success notifications.
-// It sends freely for the first `window` consecutive successes, then
-// suppresses for the next `window`, then resets and sends one, and repeats.
-type notifyThrottle struct {
-	window      int
-	consecutive int // consecutive successes so far
-	suppressed  int // how many we have suppressed in the current suppression period
+// bodyIDs combines a single body id and a list into one list, dropping empty
+// and duplicate entries.
+func bodyIDs(id string, ids []string) []string {
+	var out []string
+	for _, v := range append([]string{id}, ids...) {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
-func newNotifyThrottle(window int) *notifyThrottle {
-	return &notifyThrottle{window: window}
+// selectors drops empty entries from a list of CSS selectors.
+func selectors(sels []string) []string {
+	var out []string
+	for _, s := range sels {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
 }
 
-// onSuccess returns true if a notification should be sent.
-func (t *notifyThrottle) onSuccess() bool {
-	t.consecutive++
+// checkInterval returns the base interval between checks: Interval if set,
+// otherwise def (--interval), divided by Weight.
+func (cfg *Config) checkInterval(def time.Duration) time.Duration {
+	base := def
+	if cfg.Interval > 0 {
+		base = cfg.Interval
+	}
+	if cfg.Weight > 0 {
+		base = time.Duration(float64(base) / cfg.Weight)
+	}
+	return base
+}
 
-	if t.suppressed > 0 {
-		// Currently in suppression period.
-		t.suppressed++
-		if t.suppressed > t.window {
-			// Suppression period over: reset and send one.
-			t.consecutive = 0
-			t.suppressed = 0
-			return true
+// forServices returns one Config per monitored service: cfg itself when no
+// services are listed, otherwise a copy of cfg with each service's overrides.
+// A borough preset is turned into the AppointmentURL here, after --borough.
+func (cfg *Config) forServices() []*Config {
+	if len(cfg.Services) == 0 {
+		cfg.applyBorough()
+		return []*Config{cfg}
+	}
+	var out []*Config
+	for _, svc := range cfg.Services {
+		c := *cfg
+		c.Services = nil
+		c.name = svc.Name
+		c.ServiceURL = svc.ServiceURL
+		c.AppointmentURL = svc.AppointmentURL
+		c.AppointmentURLs = svc.AppointmentURLs
+		if svc.Borough != "" {
+			c.Borough = svc.Borough
 		}
-		return false
+		if c.Borough != "" {
+			c.applyBorough() // the service's borough, or the top-level one it inherits
+		}
+		if svc.Interval > 0 {
+			c.Interval = svc.Interval
+			c.Weight = 0 // a service's own interval isn't scaled by the top-level weight
+		} else if svc.Weight > 0 {
+			c.Weight = svc.Weight
+		}
+		if ids := bodyIDs(svc.SuccessBodyID, svc.SuccessBodyIDs); len(ids) > 0 {
+			c.SuccessBodyID = ""
+			c.SuccessBodyIDs = ids
+		}
+		if svc.TakenBodyID != "" {
+			c.TakenBodyID = svc.TakenBodyID
+		}
+		if svc.MaintenanceHeadline != "" {
+			c.MaintenanceHeadline = svc.MaintenanceHeadline
+		}
+		if sels := selectors(svc.HeadlineSelectors); len(sels) > 0 {
+			c.HeadlineSelectors = sels
+		}
+		if len(svc.PreSteps) > 0 {
+			c.PreSteps = svc.PreSteps
+		}
+		if svc.SuccessText.active() {
+			c.SuccessText = svc.SuccessText
+		}
+		c.WebhookURLs = slices.Clone(cfg.WebhookURLs)
+		if svc.WebhookURL != "" && !slices.Contains(c.WebhookURLs, svc.WebhookURL) {
+			c.WebhookURLs = append(c.WebhookURLs, svc.WebhookURL)
+		}
+		out = append(out, &c)
 	}
+	return out
+}
 
-	if t.consecutive >= t.window {
-		// Just crossed the threshold: enter suppression.
-		t.suppressed = 1
-		return false
+// goneMessage is sent with --notify-on-gone once notified slots are gone.
+func (cfg *Config) goneMessage() string {
+	if cfg.name != "" {
+		return "Appointments for " + cfg.name + " are gone again"
+	}
+	return "Appointments are gone again"
+}
+
+func (cfg *Config) message() string {
+	if cfg.name != "" {
+		return "Found an Appointment for " + cfg.name + ", check " + cfg.ServiceURL
+	}
+	return "Found an Appointment, check " + cfg.ServiceURL
+}
+
+// messageData is what message_template is rendered with.
+type messageData struct {
+	URL          string // service page
+	Service      string // service name; empty for the single top-level service
+	Headline     string
+	Status       int64
+	Time         time.Time // when the check ran
+	Dates        []string  // bookable days as YYYY-MM-DD (Berlin); empty if unknown
+	Availability string    // e.g. "3 days with slots"
+	Endpoint     string    // the appointment_urls entry that showed slots; empty without mirrors
+}
+
+// successMessage renders message_template with d. Without a template, or if it
+// fails to render, it is message() followed by the availability and, with
+// appointment_urls, the endpoint.
+func (cfg *Config) successMessage(d messageData) string {
+	if cfg.messageTmpl != nil {
+		var b strings.Builder
+		err := cfg.messageTmpl.Execute(&b, d)
+		if err == nil {
+			return b.String()
+		}
+		log.Printf("message_template: %v — using the default message", err)
+	}
+	if d.Endpoint != "" {
+		return cfg.message() + " (" + d.Availability + ", at " + d.Endpoint + ")"
+	}
+	return cfg.message() + " (" + d.Availability + ")"
+}
+
+// backoffState tracks the delay between checks. Each consecutive failure
+// doubles the delay up to max; a completed check resets it to base.
+type backoffState struct {
+	base    time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+func newBackoffState(base, max time.Duration) *backoffState {
+	if max < base {
+		max = base
+	}
+	return &backoffState{base: base, max: max, current: base}
+}
+
+// onFailure doubles the delay (capped at max) and returns it.
+func (b *backoffState) onFailure() time.Duration {
+	b.current *= 2
+	if b.current > b.max {
+		b.current = b.max
 	}
+	return b.current
+}
 
-	return true
+// onSuccess resets the delay to base and returns it.
+func (b *backoffState) onSuccess() time.Duration {
+	b.current = b.base
+	return b.current
 }
 
-// onFailure resets all state.
-func (t *notifyThrottle) onFailure() {
-	t.consecutive = 0
-	t.suppressed = 0
+// setBase changes the base delay and restarts from it, raising max if needed.
+func (b *backoffState) setBase(base time.Duration) {
+	b.base = base
+	b.max = max(b.max, base)
+	b.current = base
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run is main without the exit, so deferred cleanup runs before the process
+// exits with the returned status code.
+func run() int {
 	interval          := flag.Duration("interval", 1*time.Minute, "retry interval (e.g. 20s, 1m, 2m30s)")
-	configFile        := flag.String("config", "config.yaml", "path to config file")
+	configFile        := flag.String("config", "config.yaml", "path to config file (YAML, or JSON/TOML by extension)")
 	alwaysCallWebhook := flag.Bool("always-call-webhook", false, "call webhook on every check (useful for testing)")
 	notifyWindow      := flag.Int("notify-window", 5, "suppress notifications after this many consecutive successes; re-notify after the same count")
 	showBrowser       := flag.Bool("show-browser", false, "show the browser window (useful for debugging)")
+	stateFile         := flag.String("state-file", "state.json", "file to persist notification throttle state across restarts (empty to disable)")
+	startupDelay      := flag.Duration("startup-delay", 0, "wait this long before the first check")
+	startupJitter     := flag.Duration("startup-jitter", 0, "add a random wait of up to this long before the first check, different per service, to desynchronize instances started at the same time")
+	jitter            := flag.Float64("jitter", 0, "randomize each wait by up to this fraction of the interval (e.g. 0.2 for ±20%)")
+	metricsAddr       := flag.String("metrics-addr", "", "serve Prometheus metrics on this address (e.g. :9090); empty disables")
+	dryRun            := flag.Bool("dry-run", false, "run checks but only log the notifications that would be sent")
+	maxErrors         := flag.Int("max-consecutive-errors", 5, "restart the browser after this many consecutive check errors (0 disables)")
+	within            := flag.Duration("within", 0, "only notify for slots within this duration from now (e.g. 336h for 14 days); 0 disables")
+	debug             := flag.Bool("debug", false, "also log debug details, such as every document and XHR response with its status and MIME type")
+	flag.BoolVar(debug, "v", false, "shorthand for --debug")
+	logFormat         := flag.String("log-format", "text", "log output format: text or json")
+	otelEndpoint      := flag.String("otel-endpoint", "", "send a trace of every check to this OTLP/HTTP collector (e.g. http://localhost:4318); empty disables")
+	dashboardAddr     := flag.String("dashboard-addr", "", "serve an HTML status page on this address (e.g. :8081); empty disables")
+	healthAddr        := flag.String("health-addr", "", "serve a /healthz liveness endpoint on this address (e.g. :8080); empty disables")
+	proxyFailures     := flag.Int("proxy-failures", 3, "with several proxies, switch to the next after this many consecutive errors, bot challenges or unexpected pages")
+	proxy             := flag.String("proxy", "", "route browser traffic through this proxy (http://, https:// or socks5://); overrides proxy_url")
+	preflightMode     := flag.String("webhook-preflight", "off", "send a test message to every webhook at startup: off, warn (log failures) or strict (exit with status 2 on a failure)")
+	webhookAttempts   := flag.Int("webhook-attempts", 3, "attempts per webhook call; network errors and 5xx responses are retried with backoff")
+	checkTimeout      := flag.Duration("check-timeout", 45*time.Second, "give up on a single check after this long")
+	notifyCooldown    := flag.Duration("notify-cooldown", 0, "after a notification, suppress further ones for this long (replaces --notify-window when set)")
+	blockedInterval   := flag.Duration("blocked-interval", time.Hour, "minimum wait after a WAF block page is detected")
+	captchaInterval   := flag.Duration("captcha-interval", 15*time.Minute, "minimum wait after a CAPTCHA/bot-challenge page is detected")
+	maintInterval     := flag.Duration("maintenance-interval", 30*time.Minute, "wait after a maintenance page that doesn't say when maintenance ends")
+	errorInterval     := flag.Duration("error-interval", 0, "minimum wait after a failed check or unexpected page (e.g. 5m); 0 uses the normal backoff")
+	auditPath         := flag.String("audit-log", "", "append one JSON line per check to this file; empty disables")
+	dbPath            := flag.String("db", "", "record every found appointment in this SQLite database; empty disables")
+	successPause      := flag.Duration("post-success-pause", 0, "after a notified success, stop checking entirely for this long so you can book (e.g. 10m); 0 disables")
+	notifyOnGone      := flag.Bool("notify-on-gone", false, "after notifying about slots, send one more message when the next check finds none")
+	borough           := flag.String("borough", "", "book the service in all locations of this borough (e.g. mitte, pankow, neukoelln) instead of clicking through to Mitte; appointment_url overrides it")
+	maxTabs           := flag.Int("max-tabs", 3, "with appointment_urls, load at most this many endpoints in parallel")
+	renderEvery       := flag.Int("full-render-every", 10, "with availability_url, render the booking page in Chrome every this many checks (refreshing cookies) and use the JSON endpoint in between")
+	idleAfter         := flag.Int("idle-after", 0, "after this many \"no slots\" pages in a row, slow down by --idle-step per further one (0 disables)")
+	idleStep          := flag.Duration("idle-step", time.Minute, "how much the idle ramp adds to the interval per \"no slots\" page")
+	idleMax           := flag.Duration("idle-max", 15*time.Minute, "longest interval the idle ramp slows down to")
+	fastInterval      := flag.Duration("fast-interval", 0, "right after slots were seen, check this often, easing back to the normal interval over --fast-window (e.g. 15s); 0 disables")
+	fastWindow        := flag.Duration("fast-window", 30*time.Minute, "how long checks stay faster after slots were seen")
+	maxInterval       := flag.Duration("max-interval", 10*time.Minute, "upper bound for the retry interval when backing off after consecutive failures")
+	debugDir          := flag.String("debug-dir", "", "save the HTML of every unexpected page to a timestamped file in this directory; empty disables")
+	snapshotOnChange  := flag.Bool("snapshot-on-change", false, "save a screenshot to --screenshot-dir whenever the site state changes (e.g. available → taken → maintenance)")
+	screenshotDir     := flag.String("screenshot-dir", "screenshots", "where --snapshot-on-change saves screenshots")
+	userDataDir       := flag.String("user-data-dir", "", "keep the Chrome profile (cookies, local storage) in this directory across restarts; empty uses a fresh profile")
+	bell              := flag.Bool("bell", true, "ring the terminal bell when an appointment is found")
+	bellCount         := flag.Int("bell-count", 1, "ring the bell this many times, a short pause apart")
+	openBrowser       := flag.Bool("open-browser", false, "open the booking page in the default browser on success (xdg-open, open or rundll32)")
+	desktopNotify     := flag.Bool("desktop-notify", false, "show a desktop notification on success (notify-send, osascript or msg)")
+	direct            := flag.Bool("direct", false, "go straight to the booking page after a fixed 2s, without the randomized dwell and Referer")
+	dwellMin          := flag.Duration("dwell-min", 2*time.Second, "shortest random wait on the service page before opening the booking page")
+	dwellMax          := flag.Duration("dwell-max", 6*time.Second, "longest random wait on the service page before opening the booking page")
+	fetchSlots        := flag.Bool("fetch-slots", false, "on success, open the first available day and include its time slots in the notification")
+	maxRuntime        := flag.Duration("max-runtime", 0, "shut down cleanly after running this long (e.g. 6h); 0 runs until stopped")
+	chromePath        := flag.String("chrome-path", "", "path to the Chrome/Chromium binary; empty searches the usual locations")
+	heartbeatInterval := flag.Duration("heartbeat-interval", 0, "post a sign of life this often (e.g. 6h); overrides heartbeat_interval; 0 uses the config")
+	confirm           := flag.Bool("confirm", false, "re-check a detected appointment once before notifying, to filter out pages that flash up briefly")
+	confirmDelay      := flag.Duration("confirm-delay", 3*time.Second, "wait this long before the --confirm re-check")
+	breakerThreshold  := flag.Int("breaker-threshold", 0, "after this many consecutive errors or 5xx responses, pause for --breaker-cooldown and then probe once (0 disables)")
+	breakerCooldown   := flag.Duration("breaker-cooldown", 30*time.Minute, "how long the circuit breaker pauses checking once it opens")
+	tuiMode           := flag.Bool("tui", false, "show a live terminal UI with the next check, last result, throttle state and log; c checks now, p pauses, q quits")
+	drainTimeout      := flag.Duration("drain-timeout", 10*time.Second, "on shutdown, give notifications still being sent this long to finish")
+	maxNotifications  := flag.Int("max-notifications", 0, "send at most this many notifications in this run, across all services, then only log further appointments; 0 means unlimited")
+	dedupTTL          := flag.Duration("dedup-ttl", 0, "suppress a notification identical to one sent within this long, across all services (e.g. 30m); 0 disables")
+	maxChecksPerHour  := flag.Int("max-checks-per-hour", 0, "never load the booking page more often than this per hour, across all services (0 disables)")
+	validateOnly      := flag.Bool("validate-config", false, "check the config file, print what is enabled and exit: 0 if valid, 1 if not")
+	exitOnSuccess     := flag.Bool("exit-on-success", false, "exit with status 0 after the first success notification has been sent")
+	showVersion       := flag.Bool("version", false, "print the version, commit and build date and exit")
+	once              := flag.Bool("once", false, "check once and exit: 0 if an appointment was found, 1 if not, 2 on error")
 	flag.Parse()
 
-	var cfg *Config
-	if c, err := loadConfig(*configFile); err != nil {
-		log.Printf("config: not loaded (%v) — webhook disabled", err)
-	} else {
-		cfg = c
-		if cfg.WebhookURL != "" {
-			log.Printf("config: webhook → %s", cfg.WebhookURL)
+	if *showVersion {
+		printVersion()
+		return 0
+	}
+	if err := setupLogging(*logFormat, *debug); err != nil {
+		log.Fatalf("--log-format: %v", err)
+	}
+	if b := *borough; b != "" && boroughs[strings.ToLower(b)] == nil {
+		log.Fatalf("--borough: unknown borough %q (known: %s)", b, knownBoroughs())
+	}
+	if err := checkPreflightMode(*preflightMode); err != nil {
+		log.Fatalf("--webhook-preflight: %v", err)
+	}
+	if *tuiMode && (*once || *logFormat != "text") {
+		log.Fatalf("--tui: needs the text log format and cannot be combined with --once")
+	}
+	if *validateOnly {
+		return checkConfig(*configFile)
+	}
+	log.Printf("terminator %s", versionString())
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Printf("config: not loaded (%v) — using defaults and TERMINATOR_* environment variables", err)
+		cfg = &Config{}
+		applyEnv(cfg, os.LookupEnv)
+		cfg.validate()
+	}
+	log.Printf("config: effective %s", effectiveConfig(cfg))
+	for _, u := range cfg.WebhookURLs {
+		log.Printf("config: webhook → %s", redactURL(u))
+	}
+	if cfg.TelegramBotToken != "" {
+		log.Printf("config: telegram → chat %s", cfg.TelegramChatID)
+	}
+	if cfg.DiscordWebhook != "" {
+		log.Printf("config: discord → enabled")
+	}
+	if cfg.SlackWebhook != "" {
+		log.Printf("config: slack → enabled")
+	}
+	if cfg.ErrorWebhookURL != "" {
+		log.Printf("config: error webhook → %s (after %d consecutive failures)", redactURL(cfg.ErrorWebhookURL), cfg.ErrorThreshold)
+	}
+	if cfg.NtfyTopic != "" {
+		log.Printf("config: ntfy → %s", redactURL(strings.TrimRight(cfg.NtfyServer, "/")+"/"+cfg.NtfyTopic))
+	}
+	if cfg.PushoverToken != "" {
+		log.Printf("config: pushover → enabled")
+	}
+	if cfg.GotifyURL != "" {
+		log.Printf("config: gotify → %s", redactURL(cfg.GotifyURL))
+	}
+	if cfg.WhatsAppToken != "" {
+		log.Printf("config: whatsapp → enabled")
+	}
+	if cfg.MatrixRoomID != "" {
+		log.Printf("config: matrix → %s on %s", cfg.MatrixRoomID, cfg.MatrixHomeserver)
+	}
+	if cfg.SMTPHost != "" {
+		log.Printf("config: email → %s via %s:%d", cfg.SMTPTo, cfg.SMTPHost, cfg.SMTPPort)
+	}
+	if cfg.quietLoc != nil {
+		log.Printf("config: quiet hours %s–%s (%s)", cfg.QuietHoursStart, cfg.QuietHoursEnd, cfg.quietLoc)
+	}
+	if cfg.activeOn {
+		log.Printf("config: active hours %s–%s (Europe/Berlin)", cfg.ActiveHoursStart, cfg.ActiveHoursEnd)
+	}
+	// applyFlags copies flag values into a freshly loaded config, at startup
+	// and on every reload.
+	applyFlags := func(c *Config) {
+		c.dates.within = *within
+		c.webhookAttempts = *webhookAttempts
+		c.desktopNotify = *desktopNotify
+		c.openBrowser = *openBrowser
+		c.bellCount = 0
+		if *bell {
+			c.bellCount = max(*bellCount, 1)
+		}
+		if *proxy != "" {
+			c.ProxyURL = *proxy
+		}
+		if *borough != "" {
+			c.Borough = *borough
+		}
+		if *heartbeatInterval > 0 {
+			c.HeartbeatInterval = *heartbeatInterval
+		}
+	}
+	applyFlags(cfg)
+	services := cfg.forServices()
+	for _, c := range services {
+		prefix := ""
+		if c.name != "" {
+			prefix = "[" + c.name + "] "
+		}
+		log.Printf("config: %sservice → %s", prefix, c.ServiceURL)
+		if c.name != "" && (c.Interval > 0 || c.Weight > 0) {
+			log.Printf("config: %sinterval → %s", prefix, c.checkInterval(*interval))
+		}
+		if c.AppointmentURL != "" {
+			log.Printf("config: %sappointment → %s", prefix, c.AppointmentURL)
+		}
+		for _, u := range c.AppointmentURLs {
+			log.Printf("config: %smirror → %s", prefix, u)
 		}
 	}
 
+	headless := !*showBrowser && (cfg.Headless == nil || *cfg.Headless)
 	opts := chromedp.DefaultExecAllocatorOptions[:]
 	opts = append(opts,
-		chromedp.Flag("headless", !*showBrowser),
+		chromedp.Flag("headless", headless),
 		chromedp.Flag("disable-blink-features", "AutomationControlled"),
-		chromedp.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/145.0.0.0 Safari/537.36"),
 	)
+	if *chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(*chromePath))
+	}
+	if cfg.WindowWidth > 0 {
+		opts = append(opts, chromedp.WindowSize(cfg.WindowWidth, cfg.WindowHeight))
+		log.Printf("config: window size %dx%d", cfg.WindowWidth, cfg.WindowHeight)
+	}
 
-	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer allocCancel()
+	// --proxy replaces the whole list; otherwise proxy_url comes first.
+	rawProxies := cfg.Proxies
+	if *proxy != "" {
+		rawProxies = nil
+	}
+	if cfg.ProxyURL != "" {
+		rawProxies = append([]string{cfg.ProxyURL}, rawProxies...)
+	}
+	var proxies []*url.URL
+	for _, raw := range rawProxies {
+		u, err := parseProxy(raw)
+		if err != nil {
+			log.Fatalf("proxy: %v", err)
+		}
+		proxies = append(proxies, u)
+		log.Printf("config: proxy → %s", u.Redacted())
+	}
+	if len(proxies) > 1 {
+		log.Printf("config: rotating through %d proxies after %d consecutive failed checks", len(proxies), *proxyFailures)
+	}
 
-	ctx, browserCancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
-	defer browserCancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		s := <-sig
-		log.Printf("received %s, shutting down", s)
-		browserCancel()
-	}()
+	if cfg.bindIP != nil {
+		if len(proxies) > 0 {
+			log.Printf("config: bind_address ignored — the proxy makes the outgoing connections")
+		} else {
+			u, err := startBindProxy(ctx, cfg.bindIP)
+			if err != nil {
+				log.Fatalf("bind_address: %v", err)
+			}
+			proxies = []*url.URL{u}
+			log.Printf("network: browser connections bound to %s (through a local proxy on %s)", cfg.bindIP, u.Host)
+		}
+	}
 
-	log.Printf("retry interval: %s, notify window: %d", *interval, *notifyWindow)
-	snipe(ctx, *interval, cfg, *alwaysCallWebhook, newNotifyThrottle(*notifyWindow))
-}
+	if *jitter < 0 || *jitter > 1 {
+		log.Fatalf("--jitter must be between 0 and 1, got %g", *jitter)
+	}
+	if *dwellMin < 0 || *dwellMax < *dwellMin {
+		log.Fatalf("--dwell-min and --dwell-max must satisfy 0 <= min <= max, got %s and %s", *dwellMin, *dwellMax)
+	}
 
-func snipe(ctx context.Context, retryEvery time.Duration, cfg *Config, alwaysCallWebhook bool, throttle *notifyThrottle) {
-	for {
-		log.Printf("--- checking appointments ---")
+	if *dryRun {
+		log.Printf("dry-run: notifications will be logged, not sent")
+	}
+	base := cfg.checkInterval(*interval)
+	log.Printf("retry interval: %s (max %s, jitter ±%g%%), notify window: %d", base, *maxInterval, *jitter*100, *notifyWindow)
+	var m *metrics
+	if *metricsAddr != "" {
+		m = newMetrics()
+		serveMetrics(ctx, *metricsAddr, m)
+	}
+	var h *health
+	if *healthAddr != "" {
+		h = newHealth(services, *interval, *checkTimeout)
+		serveHealth(ctx, *healthAddr, h)
+	}
+	tr, err := newTracer(*otelEndpoint)
+	if err != nil {
+		log.Fatalf("--otel-endpoint: %v", err)
+	}
+	if tr != nil {
+		log.Printf("otel: exporting traces to %s", redactURL(tr.url))
+	}
+	var ui *tui
+	if *tuiMode {
+		ui = newTUI()
+	}
+	var dash *dashboard
+	if *dashboardAddr != "" {
+		dash = newDashboard()
+		serveDashboard(ctx, *dashboardAddr, dash)
+	}
 
-		var lastStatus atomic.Int64
-		chromedp.ListenTarget(ctx, func(ev interface{}) {
-			if e, ok := ev.(*network.EventResponseReceived); ok {
-				if e.Type == network.ResourceTypeDocument {
-					lastStatus.Store(e.Response.Status)
-				}
-			}
-		})
+	var audit *auditLog
+	if *auditPath != "" {
+		if audit, err = openAuditLog(*auditPath); err != nil {
+			log.Printf("audit: cannot open %s (%v) — audit log disabled", *auditPath, err)
+		} else {
+			defer audit.Close()
+			log.Printf("audit: appending to %s", *auditPath)
+		}
+	}
+	var hist *history
+	if *dbPath != "" {
+		if hist, err = openHistory(*dbPath); err != nil {
+			log.Printf("db: cannot open %s (%v) — history disabled", *dbPath, err)
+		} else {
+			defer hist.Close()
+			log.Printf("db: recording appointments in %s", *dbPath)
+		}
+	}
+	if *debugDir != "" {
+		if err := os.MkdirAll(*debugDir, 0o755); err != nil {
+			log.Printf("debug: cannot create %s (%v) — page dumps disabled", *debugDir, err)
+			*debugDir = ""
+		} else {
+			log.Printf("debug: saving unexpected pages to %s", *debugDir)
+		}
+	}
+	if *snapshotOnChange {
+		if err := os.MkdirAll(*screenshotDir, 0o755); err != nil {
+			log.Printf("screenshot: cannot create %s (%v) — snapshots disabled", *screenshotDir, err)
+			*snapshotOnChange = false
+		} else {
+			log.Printf("screenshot: saving a screenshot on every state change to %s", *screenshotDir)
+		}
+	}
 
-		const mitteBtn = `#service_locationlist_checkboxgroup > fieldset > div:nth-child(1) > ul:nth-child(6) > li:nth-child(2) > div.listitem__footer > div > a`
-
-		var bodyID, currentURL, headline string
-		err := chromedp.Run(ctx,
-			network.Enable(),
-			chromedp.Evaluate(`Object.defineProperty(navigator, 'webdriver', {get: () => undefined})`, nil),
-			chromedp.Navigate(serviceURL),
-			chromedp.Sleep(2*time.Second),
-			chromedp.ScrollIntoView(mitteBtn, chromedp.ByQuery),
-			chromedp.Sleep(500*time.Millisecond),
-			chromedp.Click(mitteBtn, chromedp.ByQuery),
-			chromedp.Evaluate("document.body.id", &bodyID),
-			chromedp.Evaluate("window.location.href", &currentURL),
-			chromedp.ActionFunc(func(ctx context.Context) error {
-				_ = chromedp.Text("h2", &headline).Do(ctx)
-				if headline == "" {
-					_ = chromedp.Text("h1", &headline).Do(ctx)
-				}
-				return nil
-			}),
-		)
+	var hb *heartbeat
+	if !*once {
+		hb = newHeartbeat(cfg, cfg.HeartbeatInterval, *dryRun)
+	}
+	if hb != nil {
+		go hb.run(ctx)
+		log.Printf("heartbeat: every %s", cfg.HeartbeatInterval)
+	}
 
-		if err != nil {
-			if ctx.Err() != nil {
-				return
+	dd := newDedup(*dedupTTL, realClock{})
+	notifyCap := newNotifyCap(*maxNotifications)
+	pause := newBookingPause(*successPause, realClock{})
+	notifyDrain := &drain{timeout: *drainTimeout}
+	budget := newCheckBudget(*maxChecksPerHour, realClock{})
+	snipers := make([]*sniper, len(services))
+	for i, c := range services {
+		s := &sniper{
+			log:               logger,
+			clock:             realClock{},
+			interval:          *interval,
+			backoff:           newBackoffState(base, *maxInterval),
+			throttle:          newThrottle(*notifyWindow, *notifyCooldown, realClock{}),
+			alwaysCallWebhook: *alwaysCallWebhook,
+			jitter:            *jitter,
+			metrics:           m,
+			allocOpts:         opts,
+			maxErrors:         *maxErrors,
+			proxies:           proxies,
+			proxyFailures:     *proxyFailures,
+			health:            h,
+			checkTimeout:      *checkTimeout,
+			captchaInterval:   *captchaInterval,
+			blockedInterval:   *blockedInterval,
+			errorInterval:     *errorInterval,
+			maintInterval:     *maintInterval,
+			direct:            *direct,
+			dwellMin:          *dwellMin,
+			dwellMax:          *dwellMax,
+			audit:             audit,
+			history:           hist,
+			heartbeat:         hb,
+			dryRun:            *dryRun,
+			fetchSlots:        *fetchSlots,
+			confirm:           *confirm,
+			confirmDelay:      *confirmDelay,
+			dedup:             dd,
+			notifyCap:         notifyCap,
+			drain:             notifyDrain,
+			budget:            budget,
+			dashboard:         dash,
+			tui:               ui,
+			tracer:            tr,
+			checkNow:          ui.newCheckNow(),
+			fastInterval:      *fastInterval,
+			fastWindow:        *fastWindow,
+			notifyOnGone:      *notifyOnGone,
+			pause:             pause,
+			maxTabs:           *maxTabs,
+			startupDelay:      *startupDelay,
+			debugDir:          *debugDir,
+			snapshotChanges:   *snapshotOnChange,
+			idleAfter:         *idleAfter,
+			idleStep:          *idleStep,
+			idleMax:           *idleMax,
+			renderEvery:       max(*renderEvery, 1),
+			screenshotDir:     *screenshotDir,
+		}
+		if *startupJitter > 0 {
+			s.startupDelay += rand.N(*startupJitter)
+		}
+		if *exitOnSuccess {
+			s.stop = cancel
+		}
+		statePath := *stateFile
+		if c.name != "" {
+			s.log = logger.With("service", c.name)
+			if statePath != "" {
+				ext := filepath.Ext(statePath)
+				statePath = strings.TrimSuffix(statePath, ext) + "-" + c.name + ext
 			}
-			log.Printf("error: %v — retrying in %s", err, retryEvery)
-			throttle.onFailure()
-		} else {
-			status := lastStatus.Load()
-			headline = strings.TrimSpace(headline)
-			log.Printf("status=%d body.id=%q url=%s", status, bodyID, currentURL)
-			if headline != "" {
-				log.Printf("headline: %q", headline)
+		}
+		s.store = newStore(statePath)
+		s.breaker = newCircuitBreaker(*breakerThreshold, *breakerCooldown, s.log)
+		if len(proxies) > 0 {
+			// Start the services on different proxies.
+			s.proxy = proxies[i%len(proxies)]
+		}
+		if *userDataDir != "" {
+			dir := *userDataDir
+			if c.name != "" {
+				dir = filepath.Join(dir, c.name)
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				log.Fatalf("--user-data-dir: %v", err)
+			}
+			s.allocOpts = append(slices.Clip(opts), chromedp.UserDataDir(dir))
+			s.logf("browser: profile in %s", dir)
+		}
+		if statePath != "" {
+			if err := s.store.LoadThrottle(s.throttle); err != nil {
+				s.logf("state: could not load %s (%v) — starting fresh", statePath, err)
+			} else {
+				s.logf("state: throttle %s (%s)", s.throttle, statePath)
+			}
+			ext := filepath.Ext(statePath)
+			s.gapsFile = strings.TrimSuffix(statePath, ext) + "-gaps" + ext
+			if err := s.gaps.load(s.gapsFile); err != nil {
+				s.logf("state: could not load %s (%v) — starting fresh", s.gapsFile, err)
+			} else if n, mean, minGap := s.gaps.summary(); n > 0 {
+				m.setGaps(c.name, n, mean, minGap)
+			}
+		}
+		s.setConfig(c)
+		if statePath != "" && len(s.notifierThrottles) > 0 {
+			if err := s.store.LoadNotifierThrottles(s.notifierThrottles); err != nil {
+				s.logf("state: could not load notifier throttles (%v) — starting fresh", err)
 			}
+		}
+		snipers[i] = s
+	}
 
-			is2xx     := status >= 200 && status < 300
-			isWartung := strings.Contains(headline, "Wartung")
-			known     := status == 429 || status == 403 || bodyID == "taken" || isWartung
-			success   := is2xx && bodyID == "dayselect"
-
-			switch {
-			case success:
-				log.Printf("!!! APPOINTMENT FOUND — slots may be available !!!")
-				if throttle.onSuccess() {
-					fmt.Print("\a")
-					if cfg != nil && cfg.WebhookURL != "" {
-						callWebhook(cfg.WebhookURL)
-					}
-				} else {
-					log.Printf("notification suppressed (consecutive successes: %d)", throttle.consecutive)
-				}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for s := range sig {
+			if s == syscall.SIGHUP {
+				reloadConfig(*configFile, applyFlags, services, snipers, hb)
+				continue
+			}
+			log.Printf("received %s, shutting down", s)
+			cancel()
+			return
+		}
+	}()
+	if *maxRuntime > 0 {
+		t := time.AfterFunc(*maxRuntime, func() {
+			log.Printf("max runtime of %s reached, shutting down", *maxRuntime)
+			cancel()
+		})
+		defer t.Stop()
+	}
 
-			case known:
-				log.Printf("no slots available, retrying in %s", retryEvery)
-				throttle.onFailure()
-				if alwaysCallWebhook && cfg != nil && cfg.WebhookURL != "" {
-					callWebhook(cfg.WebhookURL)
-				}
+	if err := preflightBrowser(ctx, opts); err != nil {
+		if ctx.Err() != nil {
+			return 0
+		}
+		log.Printf("browser: could not start Chrome: %v", err)
+		log.Printf("browser: install Google Chrome or Chromium, or point --chrome-path at the binary")
+		return 2
+	}
+	if mode := *preflightMode; mode == "warn" || mode == "strict" {
+		failed := webhookPreflight(ctx, preflightTargets(cfg, services))
+		switch {
+		case failed > 0 && mode == "strict" && ctx.Err() == nil:
+			log.Printf("webhook preflight: %d webhook(s) unreachable — exiting; fix them or use --webhook-preflight=warn", failed)
+			return 2
+		case failed > 0:
+			log.Printf("webhook preflight: %d webhook(s) unreachable — checking anyway", failed)
+		}
+	}
+
+	if ui != nil {
+		stopTUI, err := ui.start(ctx, cancel)
+		if err != nil {
+			log.Printf("--tui: %v", err)
+			return 2
+		}
+		defer stopTUI()
+	}
 
-			default:
-				log.Printf("unexpected page (id=%q), retrying in %s", bodyID, retryEvery)
-				throttle.onFailure()
-				if alwaysCallWebhook && cfg != nil && cfg.WebhookURL != "" {
-					callWebhook(cfg.WebhookURL)
+	var wg sync.WaitGroup
+	outcomes := make([]outcome, len(snipers))
+	for i, s := range snipers {
+		if *once {
+			wg.Go(func() {
+				if s.waitStartup(ctx) {
+					outcomes[i] = s.runOnce(ctx)
 				}
-			}
+			})
+		} else {
+			wg.Go(func() {
+				if s.waitStartup(ctx) {
+					s.snipe(ctx)
+				}
+			})
 		}
+	}
+	wg.Wait()
+	if !notifyDrain.wait() {
+		log.Printf("shutdown: notifications still in flight after %s — exiting anyway", *drainTimeout)
+	}
 
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(retryEvery):
+	if !*once {
+		return 0
+	}
+	return exitCode(outcomes)
+}
+
+// exitCode maps the outcomes of a --once run to the process status: 0 if any
+// service found an appointment, 1 if every check saw a known "no slots" page,
+// and 2 if any check failed or hit a page it could not classify.
+func exitCode(outcomes []outcome) int {
+	if slices.Contains(outcomes, outcomeSuccess) {
+		return 0
+	}
+	for _, o := range outcomes {
+		if o != outcomeKnown {
+			return 2
 		}
 	}
+	return 1
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUntilActive(t *testing.T) {
+	berlin := berlinLocation()
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 3, day, hour, minute, 0, 0, berlin)
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		now        time.Time
+		want       time.Duration
+	}{
+		{"inside", "06:00", "20:00", at(10, 12, 0), 0},
+		{"at start", "06:00", "20:00", at(10, 6, 0), 0},
+		{"before start", "06:00", "20:00", at(10, 5, 30), 30 * time.Minute},
+		{"at end", "06:00", "20:00", at(10, 20, 0), 10 * time.Hour},
+		{"after end", "06:00", "20:00", at(10, 23, 0), 7 * time.Hour},
+		{"wrapping, inside before midnight", "22:00", "02:00", at(10, 23, 0), 0},
+		{"wrapping, inside after midnight", "22:00", "02:00", at(10, 1, 0), 0},
+		{"wrapping, outside", "22:00", "02:00", at(10, 12, 0), 10 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{ActiveHoursStart: tt.start, ActiveHoursEnd: tt.end}
+			cfg.validateActiveHours()
+			if got := cfg.untilActive(tt.now); got != tt.want {
+				t.Errorf("untilActive(%s) = %s, want %s", tt.now.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+
+	if got := (&Config{}).untilActive(at(10, 3, 0)); got != 0 {
+		t.Errorf("without active hours: untilActive = %s, want 0", got)
+	}
+}
+
+func TestServiceWeights(t *testing.T) {
+	cfg := &Config{
+		Interval: 3 * time.Minute,
+		Services: []ServiceConfig{
+			{Name: "heavy", ServiceURL: defaultServiceURL, Weight: 3},
+			{Name: "own", ServiceURL: defaultServiceURL, Interval: 2 * time.Minute},
+			{Name: "both", ServiceURL: defaultServiceURL, Interval: 5 * time.Minute, Weight: 2},
+			{Name: "plain", ServiceURL: defaultServiceURL},
+		},
+	}
+	cfg.validate()
+	want := map[string]time.Duration{"heavy": time.Minute, "own": 2 * time.Minute, "both": 5 * time.Minute, "plain": 3 * time.Minute}
+	for _, c := range cfg.forServices() {
+		if got := c.checkInterval(time.Minute); got != want[c.name] {
+			t.Errorf("%s: interval %s, want %s", c.name, got, want[c.name])
+		}
+	}
+	if len(cfg.problems) != 1 {
+		t.Errorf("problems = %q, want one about interval and weight", cfg.problems)
+	}
+
+	cfg = &Config{
+		Interval: 3 * time.Minute,
+		Weight:   3,
+		Services: []ServiceConfig{
+			{Name: "inherits", ServiceURL: defaultServiceURL},
+			{Name: "heavier", ServiceURL: defaultServiceURL, Weight: 6},
+			{Name: "own", ServiceURL: defaultServiceURL, Interval: 2 * time.Minute},
+		},
+	}
+	cfg.validate()
+	want = map[string]time.Duration{"inherits": time.Minute, "heavier": 30 * time.Second, "own": 2 * time.Minute}
+	for _, c := range cfg.forServices() {
+		if got := c.checkInterval(time.Minute); got != want[c.name] {
+			t.Errorf("top-level weight, %s: interval %s, want %s", c.name, got, want[c.name])
+		}
+	}
+	if d := (&Config{Weight: 2}).checkInterval(time.Minute); d != 30*time.Second {
+		t.Errorf("weighted --interval: %s, want 30s", d)
+	}
+}
+
+func TestHeadlineSelectors(t *testing.T) {
+	cfg := &Config{
+		Services: []ServiceConfig{
+			{Name: "custom", ServiceURL: defaultServiceURL, HeadlineSelectors: []string{".alert-title", " ", "h1"}},
+			{Name: "plain", ServiceURL: defaultServiceURL},
+		},
+	}
+	cfg.validate()
+	if !slices.Equal(cfg.HeadlineSelectors, []string{"h2", "h1"}) {
+		t.Errorf("default headline_selectors = %q, want h2, h1", cfg.HeadlineSelectors)
+	}
+	want := map[string][]string{"custom": {".alert-title", "h1"}, "plain": {"h2", "h1"}}
+	for _, c := range cfg.forServices() {
+		if !slices.Equal(c.HeadlineSelectors, want[c.name]) {
+			t.Errorf("%s: headline_selectors = %q, want %q", c.name, c.HeadlineSelectors, want[c.name])
+		}
+	}
+}
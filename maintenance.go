@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// maintenanceBuffer is added to a parsed maintenance end time, since the site
+// rarely comes back on the minute.
+const maintenanceBuffer = 2 * time.Minute
+
+// maintenanceEndRe matches the times on the maintenance page, e.g. "bis
+// 14:00 Uhr", "bis ca. 6.30 Uhr" or "bis 12.03.2025, 06:00 Uhr". The date
+// and year are optional.
+var maintenanceEndRe = regexp.MustCompile(`(?:(\d{1,2})\.(\d{1,2})\.(\d{4})?\s*,?\s*(?:um\s*)?)?(\d{1,2})[:.](\d{2})\s*Uhr`)
+
+// maintenanceTextJS returns the visible text of the page, which may state
+// when maintenance ends.
+const maintenanceTextJS = `document.body ? document.body.innerText.slice(0, 5000) : ''`
+
+// parseMaintenanceEnd returns the latest time mentioned in text, read as
+// Berlin time, if it lies after now and within two days. A time without a
+// date is taken as today; if that has already passed the announcement is
+// stale and nothing is returned.
+func parseMaintenanceEnd(text string, now time.Time) (time.Time, bool) {
+	loc := berlinLocation()
+	local := now.In(loc)
+	var end time.Time
+	for _, m := range maintenanceEndRe.FindAllStringSubmatch(text, -1) {
+		year, month, day := local.Date()
+		if m[1] != "" {
+			d, _ := strconv.Atoi(m[1])
+			mo, _ := strconv.Atoi(m[2])
+			day, month = d, time.Month(mo)
+			if m[3] != "" {
+				year, _ = strconv.Atoi(m[3])
+			}
+		}
+		hour, _ := strconv.Atoi(m[4])
+		minute, _ := strconv.Atoi(m[5])
+		if hour > 24 || minute > 59 || month < 1 || month > 12 || day < 1 || day > 31 {
+			continue
+		}
+		if t := time.Date(year, month, day, hour, minute, 0, 0, loc); t.After(end) {
+			end = t
+		}
+	}
+	if !end.After(now) || end.Sub(now) > 48*time.Hour {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+// maintenanceWait returns how long to wait after a maintenance page: until
+// the end time stated on the page plus maintenanceBuffer, or
+// --maintenance-interval when the page names none.
+func (s *sniper) maintenanceWait(browserCtx context.Context, headline string) time.Duration {
+	now := s.clock.Now()
+	end, ok := parseMaintenanceEnd(headline, now)
+	if !ok {
+		var text string
+		if err := chromedp.Run(browserCtx, chromedp.Evaluate(maintenanceTextJS, &text)); err == nil {
+			end, ok = parseMaintenanceEnd(text, now)
+		}
+	}
+	if !ok {
+		s.log.Info("site under maintenance, no end time found", "retry_in", s.maintInterval.String())
+		return s.maintInterval
+	}
+	wait := end.Sub(now) + maintenanceBuffer
+	s.log.Info("site under maintenance", "until", end.Format("2006-01-02 15:04"), "retry_in", wait.Round(time.Second).String())
+	return wait
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceEnd(t *testing.T) {
+	loc := berlinLocation()
+	now := time.Date(2025, 3, 12, 10, 0, 0, 0, loc)
+	tests := []struct {
+		text string
+		want time.Time // zero when nothing should be found
+	}{
+		{"Wartungsarbeiten bis 14:00 Uhr", time.Date(2025, 3, 12, 14, 0, 0, 0, loc)},
+		{"Wartung bis ca. 11.30 Uhr", time.Date(2025, 3, 12, 11, 30, 0, 0, loc)},
+		{"Wartung von 08:00 Uhr bis 12:15 Uhr", time.Date(2025, 3, 12, 12, 15, 0, 0, loc)},
+		{"Wartung bis 13.03.2025, 06:00 Uhr", time.Date(2025, 3, 13, 6, 0, 0, 0, loc)},
+		{"Wartung bis 13.03. um 6:00 Uhr", time.Date(2025, 3, 13, 6, 0, 0, 0, loc)},
+		{"Wartung bis 09:00 Uhr", time.Time{}},            // already over
+		{"Wartung bis 20.03.2025 06:00 Uhr", time.Time{}}, // too far ahead
+		{"Wartung", time.Time{}},
+	}
+	for _, tt := range tests {
+		got, ok := parseMaintenanceEnd(tt.text, now)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("parseMaintenanceEnd(%q) = %v, %v; want %v", tt.text, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkDurationBuckets are the histogram upper bounds, in seconds, for
+// terminator_check_duration_seconds.
+var checkDurationBuckets = []float64{1, 2.5, 5, 7.5, 10, 15, 20, 30, 45, 60}
+
+// pageTimingBuckets are the histogram upper bounds, in seconds, for
+// terminator_page_response_seconds.
+var pageTimingBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20}
+
+// histogram is a Prometheus histogram without labels of its own.
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // per bucket, non-cumulative
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(secs float64) {
+	h.sum += secs
+	h.count++
+	for i, le := range h.buckets {
+		if secs <= le {
+			h.counts[i]++
+			break
+		}
+	}
+}
+
+// write renders h as name, adding labels (e.g. `phase="ttfb",`) to each line.
+func (h *histogram) write(w io.Writer, name, labels string) {
+	var cum uint64
+	for i, le := range h.buckets {
+		cum += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", name, labels, le, cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, labels, h.count)
+	if labels != "" {
+		labels = "{" + strings.TrimSuffix(labels, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, h.count)
+}
+
+// metrics collects check statistics and renders them in the Prometheus text
+// exposition format. A nil *metrics is valid and records nothing.
+type metrics struct {
+	mu         sync.Mutex
+	checks     map[string]uint64 // by outcome
+	lastStatus int64
+	duration   *histogram
+	ttfb       *histogram            // appointment page time to first byte
+	pageLoad   *histogram            // appointment page time to fully loaded
+	gaps       map[string]gapSummary // by service name
+}
+
+// gapSummary is the latest gapStats summary of one service.
+type gapSummary struct {
+	n         int
+	mean, min time.Duration
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		checks:   make(map[string]uint64),
+		gaps:     make(map[string]gapSummary),
+		duration: newHistogram(checkDurationBuckets),
+		ttfb:     newHistogram(pageTimingBuckets),
+		pageLoad: newHistogram(pageTimingBuckets),
+	}
+}
+
+// observeCheck records one completed check with the given outcome
+// (success, known, captcha, blocked, unexpected or error) and how long it took.
+func (m *metrics) observeCheck(outcome string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks[outcome]++
+	m.duration.observe(d.Seconds())
+}
+
+// observePageTiming records the network timing of the appointment page.
+func (m *metrics) observePageTiming(ttfb, total time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ttfb.observe(ttfb.Seconds())
+	m.pageLoad.observe(total.Seconds())
+}
+
+// setLastStatus records the HTTP status of the most recent document response.
+func (m *metrics) setLastStatus(status int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.lastStatus = status
+	m.mu.Unlock()
+}
+
+// setGaps records the time between appointment sightings for a service.
+func (m *metrics) setGaps(service string, n int, mean, min time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.gaps[service] = gapSummary{n: n, mean: mean, min: min}
+	m.mu.Unlock()
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP terminator_checks_total Appointment checks by outcome.")
+	fmt.Fprintln(w, "# TYPE terminator_checks_total counter")
+	outcomes := make([]string, 0, len(m.checks))
+	for o := range m.checks {
+		outcomes = append(outcomes, o)
+	}
+	sort.Strings(outcomes)
+	for _, o := range outcomes {
+		fmt.Fprintf(w, "terminator_checks_total{outcome=%q} %d\n", o, m.checks[o])
+	}
+
+	fmt.Fprintln(w, "# HELP terminator_last_http_status HTTP status of the last appointment page response.")
+	fmt.Fprintln(w, "# TYPE terminator_last_http_status gauge")
+	fmt.Fprintf(w, "terminator_last_http_status %d\n", m.lastStatus)
+
+	fmt.Fprintln(w, "# HELP terminator_check_duration_seconds Time taken by one appointment check.")
+	fmt.Fprintln(w, "# TYPE terminator_check_duration_seconds histogram")
+	m.duration.write(w, "terminator_check_duration_seconds", "")
+
+	fmt.Fprintln(w, "# HELP terminator_page_response_seconds Network timing of the appointment page document.")
+	fmt.Fprintln(w, "# TYPE terminator_page_response_seconds histogram")
+	m.ttfb.write(w, "terminator_page_response_seconds", `phase="ttfb",`)
+	m.pageLoad.write(w, "terminator_page_response_seconds", `phase="total",`)
+
+	if len(m.gaps) == 0 {
+		return
+	}
+	services := make([]string, 0, len(m.gaps))
+	for s := range m.gaps {
+		services = append(services, s)
+	}
+	sort.Strings(services)
+	fmt.Fprintln(w, "# HELP terminator_appointment_gap_seconds Time between recent distinct appointment sightings.")
+	fmt.Fprintln(w, "# TYPE terminator_appointment_gap_seconds gauge")
+	for _, s := range services {
+		g := m.gaps[s]
+		fmt.Fprintf(w, "terminator_appointment_gap_seconds{service=%q,stat=\"mean\"} %g\n", s, g.mean.Seconds())
+		fmt.Fprintf(w, "terminator_appointment_gap_seconds{service=%q,stat=\"min\"} %g\n", s, g.min.Seconds())
+	}
+	fmt.Fprintln(w, "# HELP terminator_appointment_gaps Number of gaps the appointment gap statistics cover.")
+	fmt.Fprintln(w, "# TYPE terminator_appointment_gaps gauge")
+	for _, s := range services {
+		fmt.Fprintf(w, "terminator_appointment_gaps{service=%q} %d\n", s, m.gaps[s].n)
+	}
+}
+
+// serveMetrics exposes m on addr at /metrics until ctx is cancelled.
+func serveMetrics(ctx context.Context, addr string, m *metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	serve(ctx, "metrics", addr, mux)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// loadMirrors is loadPage for services with appointment_urls: after the
+// service page in the main tab, every endpoint is opened in its own tab, at
+// most s.maxTabs at a time. It returns the page pickMirror picks. All tabs
+// are closed before it returns.
+func (s *sniper) loadMirrors(checkCtx context.Context) (pageState, error) {
+	cfg := s.cfg
+	err := chromedp.Run(checkCtx,
+		network.Enable(),
+		proxyAuthAction(s.proxy),
+		chromedp.Evaluate(`Object.defineProperty(navigator, 'webdriver', {get: () => undefined})`, nil),
+		s.span.traced("navigate service page", chromedp.Navigate(cfg.ServiceURL)),
+		s.dwell(),
+	)
+	if err != nil {
+		return pageState{}, err
+	}
+
+	pages := make([]pageState, len(cfg.AppointmentURLs))
+	errs := make([]error, len(cfg.AppointmentURLs))
+	sem := make(chan struct{}, max(s.maxTabs, 1))
+	var wg sync.WaitGroup
+	for i, u := range cfg.AppointmentURLs {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			pages[i], errs[i] = s.loadMirror(checkCtx, u)
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			s.log.Warn("endpoint failed", "endpoint", cfg.AppointmentURLs[i], "err", err)
+		}
+	}
+	return pickMirror(cfg, pages, errs)
+}
+
+// pickMirror returns the first endpoint page that shows slots, or else the
+// first one that loaded. It fails only when every endpoint failed, with the
+// first endpoint's error, so one broken mirror doesn't turn the others'
+// "no slots" pages into an error.
+func pickMirror(cfg *Config, pages []pageState, errs []error) (pageState, error) {
+	for i, p := range pages {
+		if errs[i] == nil && cfg.isSuccessPage(p) {
+			return p, nil
+		}
+	}
+	for i, p := range pages {
+		if errs[i] == nil {
+			return p, nil
+		}
+	}
+	return pages[0], errs[0]
+}
+
+// loadMirror opens u in a new tab, which shares the main tab's cookies, and
+// reads its state. The tab is closed on return.
+func (s *sniper) loadMirror(ctx context.Context, u string) (pageState, error) {
+	cfg := s.cfg
+	tabCtx, closeTab := chromedp.NewContext(ctx)
+	defer closeTab()
+	span := s.span.child("load mirror")
+	span.set("endpoint", u)
+	defer span.finish()
+
+	var status, retryAfter atomic.Int64
+	chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+		e, ok := ev.(*network.EventResponseReceived)
+		if !ok {
+			return
+		}
+		s.logResponse(e)
+		if e.Type == network.ResourceTypeDocument {
+			status.Store(e.Response.Status)
+			ra, _ := parseRetryAfter(headerValue(e.Response.Headers, "Retry-After"), s.clock.Now())
+			retryAfter.Store(int64(ra))
+		}
+	})
+	if s.proxy != nil && s.proxy.User != nil {
+		listenProxyAuth(tabCtx, s.proxy)
+	}
+
+	referer := network.Headers{}
+	if !s.direct {
+		referer = network.Headers{"Referer": cfg.ServiceURL}
+	}
+	p := pageState{endpoint: u}
+	err := chromedp.Run(tabCtx,
+		network.Enable(),
+		proxyAuthAction(s.proxy),
+		chromedp.Evaluate(`Object.defineProperty(navigator, 'webdriver', {get: () => undefined})`, nil),
+		network.SetExtraHTTPHeaders(referer),
+		chromedp.Navigate(u),
+		preStepsAction(cfg.PreSteps),
+		waitReady(cfg),
+		s.dismissConsent(cfg),
+		readPage(&p, cfg, s.debugDir != ""),
+	)
+	if err != nil {
+		span.fail(err)
+		return pageState{endpoint: u}, err
+	}
+	p.status = status.Load()
+	span.set("http.status", p.status)
+	span.set("body_id", p.bodyID)
+	p.retryAfter = time.Duration(retryAfter.Load())
+	return p, nil
+}
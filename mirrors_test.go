@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPickMirror(t *testing.T) {
+	cfg := &Config{}
+	cfg.validate()
+	timeout := errors.New("net::ERR_TIMED_OUT")
+
+	p, err := pickMirror(cfg,
+		[]pageState{{endpoint: "a"}, {endpoint: "b", bodyID: "taken"}, {endpoint: "c", bodyID: "taken"}},
+		[]error{timeout, nil, nil})
+	if err != nil || p.endpoint != "b" {
+		t.Errorf("first mirror failed: got %q, %v; want the second mirror's page", p.endpoint, err)
+	}
+
+	p, err = pickMirror(cfg,
+		[]pageState{{endpoint: "a", status: 200, bodyID: "taken"}, {endpoint: "b", status: 200, bodyID: "dayselect"}},
+		[]error{nil, nil})
+	if err != nil || p.endpoint != "b" {
+		t.Errorf("slots on the second mirror: got %q, %v; want it", p.endpoint, err)
+	}
+
+	_, err = pickMirror(cfg, []pageState{{endpoint: "a"}, {endpoint: "b"}}, []error{timeout, errors.New("refused")})
+	if err != timeout {
+		t.Errorf("all mirrors failed: got %v, want the first error", err)
+	}
+}
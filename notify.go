@@ -0,0 +1,1003 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Notifier delivers an appointment alert through one backend.
+type Notifier interface {
+	// Name identifies the backend in logs.
+	Name() string
+	Notify(ctx context.Context, message string) error
+}
+
+// notifierNames are the Name()s of all backends, for validating config keys
+// that refer to them.
+var notifierNames = []string{"bell", "desktop", "browser", "webhook", "telegram", "discord", "slack", "ntfy", "pushover", "gotify", "whatsapp", "matrix", "email"}
+
+// successOnly are the notifiers that only make sense for an appointment:
+// they ring, pop up or open the booking page rather than deliver the text.
+var successOnly = []string{"bell", "desktop", "browser"}
+
+// messageNotifiers returns ns without the successOnly notifiers, for
+// messages that aren't about an appointment, such as slots being gone.
+func messageNotifiers(ns []Notifier) []Notifier {
+	return slices.DeleteFunc(slices.Clone(ns), func(n Notifier) bool {
+		return slices.Contains(successOnly, n.Name())
+	})
+}
+
+// newNotifiers returns the bell, unless disabled, followed by every backend
+// enabled in cfg.
+func newNotifiers(cfg *Config) []Notifier {
+	var ns []Notifier
+	if cfg.bellCount > 0 {
+		ns = append(ns, bellNotifier{count: cfg.bellCount})
+	}
+	if n := newWebhookNotifier(cfg); n != nil {
+		ns = append(ns, n)
+	}
+	if cfg.TelegramBotToken != "" {
+		ns = append(ns, &telegramNotifier{token: cfg.TelegramBotToken, chatID: cfg.TelegramChatID})
+	}
+	if cfg.SMTPHost != "" {
+		ns = append(ns, newEmailNotifier(cfg))
+	}
+	if cfg.DiscordWebhook != "" {
+		ns = append(ns, &discordNotifier{webhookURL: cfg.DiscordWebhook, serviceURL: cfg.ServiceURL})
+	}
+	if cfg.SlackWebhook != "" {
+		ns = append(ns, &slackNotifier{webhookURL: cfg.SlackWebhook, serviceURL: cfg.ServiceURL})
+	}
+	if cfg.NtfyTopic != "" {
+		ns = append(ns, &ntfyNotifier{topicURL: strings.TrimRight(cfg.NtfyServer, "/") + "/" + cfg.NtfyTopic, serviceURL: cfg.ServiceURL})
+	}
+	if cfg.desktopNotify {
+		if n := newDesktopNotifier(); n != nil {
+			ns = append(ns, n)
+		}
+	}
+	if cfg.openBrowser {
+		if n := newBrowserNotifier(cfg.ServiceURL); n != nil {
+			ns = append(ns, n)
+		}
+	}
+	if cfg.MatrixRoomID != "" {
+		ns = append(ns, &matrixNotifier{homeserver: strings.TrimRight(cfg.MatrixHomeserver, "/"), token: cfg.MatrixAccessToken, roomID: cfg.MatrixRoomID})
+	}
+	if cfg.PushoverToken != "" {
+		ns = append(ns, &pushoverNotifier{token: cfg.PushoverToken, user: cfg.PushoverUser, serviceURL: cfg.ServiceURL})
+	}
+	if cfg.WhatsAppToken != "" {
+		ns = append(ns, &whatsAppNotifier{token: cfg.WhatsAppToken, phoneID: cfg.WhatsAppPhoneID, to: cfg.WhatsAppTo})
+	}
+	if cfg.GotifyURL != "" {
+		ns = append(ns, &gotifyNotifier{server: strings.TrimRight(cfg.GotifyURL, "/"), token: cfg.GotifyToken})
+	}
+	return ns
+}
+
+// checkStatus turns a non-2xx response into an error that includes the start
+// of the response body.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// postJSON marshals v and POSTs it to u.
+func postJSON(ctx context.Context, u string, v any) (*http.Response, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return http.DefaultClient.Do(req)
+}
+
+// dryRunNotifier logs instead of delivering.
+type dryRunNotifier struct {
+	Notifier
+}
+
+func (n dryRunNotifier) Notify(ctx context.Context, message string) error {
+	log.Printf("dry-run: would notify via %s", n.Name())
+	return nil
+}
+
+// dedup suppresses notification messages identical to one sent within ttl.
+// One dedup is shared by all services, so services that show the same
+// availability notify once. A nil *dedup lets every message through.
+type dedup struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu   sync.Mutex
+	sent map[[sha256.Size]byte]time.Time // message hash → when it was last sent
+}
+
+// newDedup returns nil when ttl is 0, disabling deduplication.
+func newDedup(ttl time.Duration, clock Clock) *dedup {
+	if ttl <= 0 {
+		return nil
+	}
+	return &dedup{ttl: ttl, clock: clock, sent: make(map[[sha256.Size]byte]time.Time)}
+}
+
+// allow reports whether message may be sent, and if so remembers it.
+func (d *dedup) allow(message string) bool {
+	if d == nil {
+		return true
+	}
+	key := sha256.Sum256([]byte(message))
+	now := d.clock.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, t := range d.sent {
+		if now.Sub(t) >= d.ttl {
+			delete(d.sent, k)
+		}
+	}
+	if _, ok := d.sent[key]; ok {
+		return false
+	}
+	d.sent[key] = now
+	return true
+}
+
+// notifyCap is the --max-notifications safety valve against runaway
+// alerting: at most max notifications per process, across all services,
+// independent of the throttles. A nil *notifyCap lets every one through.
+type notifyCap struct {
+	max int
+
+	mu   sync.Mutex
+	sent int
+}
+
+// newNotifyCap returns nil when max is 0, meaning unlimited.
+func newNotifyCap(max int) *notifyCap {
+	if max <= 0 {
+		return nil
+	}
+	return &notifyCap{max: max}
+}
+
+// take reports whether one more notification may be sent, and whether it
+// is the last one the cap allows.
+func (c *notifyCap) take() (ok, last bool) {
+	if c == nil {
+		return true, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sent >= c.max {
+		return false, false
+	}
+	c.sent++
+	return true, c.sent == c.max
+}
+
+// drain keeps notifications in flight alive through a shutdown. Each send
+// gets a context that outlives the cancelled root context by up to timeout,
+// and wait lets run block until they are done before the process exits. One
+// drain is shared by all snipers; a nil *drain adds nothing.
+type drain struct {
+	timeout time.Duration
+	wg      sync.WaitGroup
+}
+
+// start returns the context to send a notification with and a func to call
+// when the send is done.
+func (d *drain) start(ctx context.Context) (context.Context, func()) {
+	if d == nil {
+		return ctx, func() {}
+	}
+	d.wg.Add(1)
+	sendCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() { time.AfterFunc(d.timeout, cancel) })
+	return sendCtx, func() {
+		stop()
+		cancel()
+		d.wg.Done()
+	}
+}
+
+// wait blocks until every notification in flight is done, or timeout has
+// passed. It reports whether they all finished.
+func (d *drain) wait() bool {
+	if d == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(d.timeout):
+		return false
+	}
+}
+
+// bellDelay separates the rings when the bell rings more than once.
+const bellDelay = 400 * time.Millisecond
+
+// bellNotifier rings the terminal bell count times. It writes to stderr, so
+// it still reaches the terminal when stdout is redirected.
+type bellNotifier struct {
+	count int
+}
+
+func (bellNotifier) Name() string { return "bell" }
+
+func (n bellNotifier) Notify(ctx context.Context, message string) error {
+	for i := range n.count {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(bellDelay):
+			}
+		}
+		fmt.Fprint(os.Stderr, "\a")
+	}
+	return nil
+}
+
+// desktopNotifier shows a desktop notification by running the platform's
+// notification tool.
+type desktopNotifier struct {
+	tool string
+	args func(title, message string) []string
+}
+
+// newDesktopNotifier returns nil, after logging why, when the platform has no
+// supported notification tool.
+func newDesktopNotifier() *desktopNotifier {
+	var n desktopNotifier
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		n.tool = "notify-send"
+		n.args = func(title, message string) []string {
+			return []string{"--urgency=critical", title, message}
+		}
+	case "darwin":
+		n.tool = "osascript"
+		n.args = func(title, message string) []string {
+			return []string{"-e", fmt.Sprintf("display notification %s with title %s", strconv.Quote(message), strconv.Quote(title))}
+		}
+	case "windows":
+		n.tool = "msg"
+		n.args = func(title, message string) []string {
+			return []string{"*", title + ": " + message}
+		}
+	default:
+		log.Printf("desktop: no notification tool known for %s — desktop notifications disabled", runtime.GOOS)
+		return nil
+	}
+	if _, err := exec.LookPath(n.tool); err != nil {
+		log.Printf("desktop: %s not found — desktop notifications disabled", n.tool)
+		return nil
+	}
+	return &n
+}
+
+func (n *desktopNotifier) Name() string { return "desktop" }
+
+func (n *desktopNotifier) Notify(ctx context.Context, message string) error {
+	out, err := exec.CommandContext(ctx, n.tool, n.args("Berlin appointment found", message)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", n.tool, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// browserOpenTimeout bounds the platform's open command.
+const browserOpenTimeout = 15 * time.Second
+
+// browserNotifier opens the booking page in the local default browser. The
+// message itself isn't shown; the page is.
+type browserNotifier struct {
+	tool string
+	args []string // the URL is appended
+	url  string
+}
+
+// newBrowserNotifier returns nil, after logging why, when the platform has no
+// known way to open a URL.
+func newBrowserNotifier(url string) *browserNotifier {
+	n := browserNotifier{url: url}
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		n.tool = "xdg-open"
+	case "darwin":
+		n.tool = "open"
+	case "windows":
+		// Unlike "cmd /c start", this doesn't need the & in the URL escaped.
+		n.tool = "rundll32"
+		n.args = []string{"url.dll,FileProtocolHandler"}
+	default:
+		log.Printf("browser: no way to open URLs known for %s — --open-browser disabled", runtime.GOOS)
+		return nil
+	}
+	if _, err := exec.LookPath(n.tool); err != nil {
+		log.Printf("browser: %s not found — --open-browser disabled", n.tool)
+		return nil
+	}
+	return &n
+}
+
+func (n *browserNotifier) Name() string { return "browser" }
+
+// Notify starts the open command and returns without waiting for it, so a
+// slow browser start doesn't hold up the other notifiers or the next check.
+// Failures are logged.
+func (n *browserNotifier) Notify(ctx context.Context, _ string) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), browserOpenTimeout)
+	cmd := exec.CommandContext(ctx, n.tool, append(slices.Clip(n.args), n.url)...)
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("%s: %w", n.tool, err)
+	}
+	go func() {
+		defer cancel()
+		if err := cmd.Wait(); err != nil {
+			log.Printf("browser: %s %s: %v", n.tool, n.url, err)
+		}
+	}()
+	return nil
+}
+
+// webhookNotifier posts to every configured webhook URL concurrently.
+type webhookNotifier struct {
+	urls        []string
+	contentType string
+	json        bool
+	tmpl        *template.Template // nil uses {"text": message}
+	serviceURL  string
+	attempts    int               // per URL, including the first
+	method      string            // empty means POST; GET sends the payload as the "message" query parameter
+	headers     map[string]string // added to every request
+	username    string            // HTTP basic auth, when set
+	password    string
+}
+
+// newWebhookNotifier returns nil when no webhook URL is configured.
+func newWebhookNotifier(cfg *Config) *webhookNotifier {
+	if len(cfg.WebhookURLs) == 0 {
+		return nil
+	}
+	return &webhookNotifier{
+		urls:        cfg.WebhookURLs,
+		contentType: cfg.WebhookContentType,
+		json:        cfg.webhookIsJSON(),
+		tmpl:        cfg.webhookTmpl,
+		serviceURL:  cfg.ServiceURL,
+		attempts:    max(cfg.webhookAttempts, 1),
+		method:      cfg.WebhookMethod,
+		headers:     cfg.WebhookHeaders,
+		username:    cfg.WebhookUsername,
+		password:    cfg.WebhookPassword,
+	}
+}
+
+// plainWebhook returns a plain-text webhook to u for the error alert and
+// heartbeat. It sends webhook_headers and basic auth like the main webhooks,
+// since it often points at the same receiver.
+func (cfg *Config) plainWebhook(u string) *webhookNotifier {
+	return &webhookNotifier{
+		urls:        []string{u},
+		contentType: "text/plain",
+		attempts:    max(cfg.webhookAttempts, 1),
+		headers:     cfg.WebhookHeaders,
+		username:    cfg.WebhookUsername,
+		password:    cfg.WebhookPassword,
+	}
+}
+
+func (n *webhookNotifier) Name() string { return "webhook" }
+
+// body renders the payload. Plain-text webhooks get the message as-is; JSON
+// webhooks get either the rendered webhook_template or {"text": message}.
+func (n *webhookNotifier) body(message string) ([]byte, error) {
+	if !n.json {
+		return []byte(message), nil
+	}
+	if n.tmpl == nil {
+		return json.Marshal(map[string]string{"text": message})
+	}
+
+	// Values are JSON-escaped (without the surrounding quotes) so they can be
+	// placed inside string literals in the template.
+	escape := func(v string) string {
+		b, _ := json.Marshal(v)
+		return string(b[1 : len(b)-1])
+	}
+	var buf bytes.Buffer
+	data := struct{ URL, Message string }{escape(n.serviceURL), escape(message)}
+	if err := n.tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("webhook_template did not render valid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// Notify returns once every webhook has answered or failed.
+func (n *webhookNotifier) Notify(ctx context.Context, message string) error {
+	body, err := n.body(message)
+	if err != nil {
+		return err
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, u := range n.urls {
+		wg.Go(func() {
+			if err := n.send(ctx, u, body); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", redactURL(u), err))
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// send delivers body to webhookURL, retrying network errors and 5xx responses
+// with exponential backoff up to n.attempts times. 4xx responses are final.
+func (n *webhookNotifier) send(ctx context.Context, webhookURL string, body []byte) error {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		retryable, err := n.sendOnce(ctx, webhookURL, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= n.attempts {
+			return err
+		}
+		log.Printf("webhook: %s attempt %d/%d failed (%v) — retrying in %s", redactURL(webhookURL), attempt, n.attempts, redactErr(err), delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// request builds the request for one attempt. GET requests carry body in the
+// "message" query parameter instead.
+func (n *webhookNotifier) request(ctx context.Context, webhookURL string, body []byte) (*http.Request, error) {
+	method := cmp.Or(n.method, http.MethodPost)
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		u, perr := url.Parse(webhookURL)
+		if perr != nil {
+			return nil, perr
+		}
+		q := u.Query()
+		q.Set("message", string(body))
+		u.RawQuery = q.Encode()
+		req, err = http.NewRequestWithContext(ctx, method, u.String(), nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, webhookURL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", n.contentType)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range n.headers {
+		req.Header.Set(k, v)
+	}
+	if n.username != "" {
+		req.SetBasicAuth(n.username, n.password)
+	}
+	return req, nil
+}
+
+// sendOnce makes a single attempt and reports whether a failure is worth retrying.
+func (n *webhookNotifier) sendOnce(ctx context.Context, webhookURL string, body []byte) (retryable bool, err error) {
+	req, err := n.request(ctx, webhookURL, body)
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("webhook: called %s → %d", redactURL(webhookURL), resp.StatusCode)
+	return resp.StatusCode >= 500, checkStatus(resp)
+}
+
+// telegramNotifier sends a message through the Telegram Bot API.
+type telegramNotifier struct {
+	token  string
+	chatID string
+}
+
+func (n *telegramNotifier) Name() string { return "telegram" }
+
+func (n *telegramNotifier) Notify(ctx context.Context, message string) error {
+	endpoint := "https://api.telegram.org/bot" + n.token + "/sendMessage"
+	form := url.Values{"chat_id": {n.chatID}, "text": {message}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.New(strings.ReplaceAll(err.Error(), n.token, "<token>"))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// The error embeds the request URL, which contains the bot token.
+		return errors.New("request failed: " + strings.ReplaceAll(err.Error(), n.token, "<token>"))
+	}
+	defer resp.Body.Close()
+	log.Printf("telegram: sent to chat %s → %d", n.chatID, resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// isDiscordWebhook reports whether u looks like https://discord.com/api/webhooks/...
+func isDiscordWebhook(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "https" {
+		return false
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(parsed.Host, "ptb."), "canary.")
+	return (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(parsed.Path, "/api/webhooks/")
+}
+
+// discordNotifier posts an embed to a Discord webhook.
+type discordNotifier struct {
+	webhookURL string
+	serviceURL string
+}
+
+func (n *discordNotifier) Name() string { return "discord" }
+
+func (n *discordNotifier) Notify(ctx context.Context, message string) error {
+	type embed struct {
+		Title       string `json:"title"`
+		URL         string `json:"url"`
+		Description string `json:"description"`
+		Timestamp   string `json:"timestamp"`
+	}
+	resp, err := postJSON(ctx, n.webhookURL, map[string][]embed{"embeds": {{
+		Title:       "Appointment found",
+		URL:         n.serviceURL,
+		Description: message,
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}}})
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("discord: sent → %d", resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// isSlackWebhook reports whether u looks like https://hooks.slack.com/services/...
+func isSlackWebhook(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "https" {
+		return false
+	}
+	return parsed.Host == "hooks.slack.com" && strings.HasPrefix(parsed.Path, "/services/")
+}
+
+// slackNotifier posts a Block Kit message with a button to the booking page
+// to a Slack incoming webhook.
+type slackNotifier struct {
+	webhookURL string
+	serviceURL string
+}
+
+func (n *slackNotifier) Name() string { return "slack" }
+
+func (n *slackNotifier) Notify(ctx context.Context, message string) error {
+	type text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	type element struct {
+		Type  string `json:"type"`
+		Text  text   `json:"text"`
+		URL   string `json:"url"`
+		Style string `json:"style"`
+	}
+	type block struct {
+		Type     string    `json:"type"`
+		Text     *text     `json:"text,omitempty"`
+		Elements []element `json:"elements,omitempty"`
+	}
+	resp, err := postJSON(ctx, n.webhookURL, struct {
+		Text   string  `json:"text"` // fallback for notifications
+		Blocks []block `json:"blocks"`
+	}{
+		Text: message,
+		Blocks: []block{
+			{Type: "section", Text: &text{Type: "mrkdwn", Text: "*Appointment found*\n" + message}},
+			{Type: "actions", Elements: []element{{
+				Type:  "button",
+				Text:  text{Type: "plain_text", Text: "Open booking page"},
+				URL:   n.serviceURL,
+				Style: "primary",
+			}}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("slack: sent → %d", resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// ntfyNotifier publishes a high-priority message to an ntfy topic.
+type ntfyNotifier struct {
+	topicURL   string // <server>/<topic>
+	serviceURL string
+}
+
+func (n *ntfyNotifier) Name() string { return "ntfy" }
+
+func (n *ntfyNotifier) Notify(ctx context.Context, message string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.topicURL, strings.NewReader(message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", "Berlin appointment found")
+	req.Header.Set("Priority", "high")
+	req.Header.Set("Tags", "calendar")
+	req.Header.Set("Click", n.serviceURL)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("ntfy: published to %s → %d", redactURL(n.topicURL), resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// pushoverNotifier sends a high-priority message through the Pushover API.
+type pushoverNotifier struct {
+	token      string
+	user       string
+	serviceURL string
+}
+
+func (n *pushoverNotifier) Name() string { return "pushover" }
+
+func (n *pushoverNotifier) Notify(ctx context.Context, message string) error {
+	form := url.Values{
+		"token":    {n.token},
+		"user":     {n.user},
+		"title":    {"Berlin appointment found"},
+		"message":  {message},
+		"priority": {"1"},
+		"url":      {n.serviceURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.pushover.net/1/messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("pushover: sent → %d", resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// gotifyNotifier pushes a high-priority message to a Gotify server.
+type gotifyNotifier struct {
+	server string // without trailing slash
+	token  string // application token
+}
+
+func (n *gotifyNotifier) Name() string { return "gotify" }
+
+func (n *gotifyNotifier) Notify(ctx context.Context, message string) error {
+	resp, err := postJSON(ctx, n.server+"/message?token="+url.QueryEscape(n.token), struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}{"Berlin appointment found", message, 8})
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("gotify: sent → %d", resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// whatsAppNotifier sends a text message through the WhatsApp Cloud API.
+type whatsAppNotifier struct {
+	token   string // access token
+	phoneID string // sender's phone number ID
+	to      string
+}
+
+func (n *whatsAppNotifier) Name() string { return "whatsapp" }
+
+func (n *whatsAppNotifier) Notify(ctx context.Context, message string) error {
+	type text struct {
+		Body       string `json:"body"`
+		PreviewURL bool   `json:"preview_url"`
+	}
+	body, err := json.Marshal(struct {
+		Product string `json:"messaging_product"`
+		To      string `json:"to"`
+		Type    string `json:"type"`
+		Text    text   `json:"text"`
+	}{"whatsapp", n.to, "text", text{Body: message, PreviewURL: true}})
+	if err != nil {
+		return err
+	}
+	endpoint := "https://graph.facebook.com/v20.0/" + url.PathEscape(n.phoneID) + "/messages"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("whatsapp: sent → %d", resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// matrixNotifier sends an m.text message to a Matrix room.
+type matrixNotifier struct {
+	homeserver string // without trailing slash
+	token      string
+	roomID     string
+}
+
+// matrixTxn makes transaction IDs unique within the process; the timestamp
+// keeps them unique across restarts.
+var matrixTxn atomic.Int64
+
+func (n *matrixNotifier) Name() string { return "matrix" }
+
+func (n *matrixNotifier) Notify(ctx context.Context, message string) error {
+	txnID := fmt.Sprintf("terminator-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	endpoint := n.homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(n.roomID) + "/send/m.room.message/" + txnID
+	body, err := json.Marshal(map[string]string{"msgtype": "m.text", "body": message})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	log.Printf("matrix: sent to %s → %d", n.roomID, resp.StatusCode)
+	return checkStatus(resp)
+}
+
+// emailTimeout bounds one email when the caller's context has no deadline.
+const emailTimeout = 30 * time.Second
+
+// emailNotifier sends a plain-text email over SMTP.
+type emailNotifier struct {
+	host string
+	addr string // host:port
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+func newEmailNotifier(cfg *Config) *emailNotifier {
+	n := &emailNotifier{
+		host: cfg.SMTPHost,
+		addr: net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		from: cfg.SMTPFrom,
+	}
+	for _, addr := range strings.Split(cfg.SMTPTo, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			n.to = append(n.to, addr)
+		}
+	}
+	if cfg.SMTPUser != "" {
+		n.auth = smtp.PlainAuth("", cfg.SMTPUser, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return n
+}
+
+func (n *emailNotifier) Name() string { return "email" }
+
+func (n *emailNotifier) Notify(ctx context.Context, message string) error {
+	msg := "From: " + n.from + "\r\n" +
+		"To: " + strings.Join(n.to, ", ") + "\r\n" +
+		"Subject: Berlin appointment found\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + message + "\r\n"
+	if err := n.send(ctx, []byte(msg)); err != nil {
+		return fmt.Errorf("send failed: %w", err)
+	}
+	log.Printf("email: sent to %s", strings.Join(n.to, ", "))
+	return nil
+}
+
+// send does what smtp.SendMail does, but on a connection bounded by ctx (or
+// emailTimeout), so a hung server can't block the check loop or shutdown.
+func (n *emailNotifier) send(ctx context.Context, msg []byte) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, emailTimeout)
+		defer cancel()
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return err
+		}
+	}
+	if n.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server doesn't support AUTH")
+		}
+		if err := c.Auth(n.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	for _, addr := range n.to {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// errorAlert calls error_webhook_url once a run of consecutive failed checks
+// reaches the threshold, and once more when a check succeeds again. A nil
+// *errorAlert is valid and does nothing.
+type errorAlert struct {
+	n         Notifier
+	name      string // service name, if any
+	threshold int
+	streak    int
+	alerted   bool
+}
+
+// newErrorAlert returns nil when no error webhook is configured.
+func newErrorAlert(cfg *Config, dryRun bool) *errorAlert {
+	if cfg.ErrorWebhookURL == "" {
+		return nil
+	}
+	var n Notifier = cfg.plainWebhook(cfg.ErrorWebhookURL)
+	if dryRun {
+		n = dryRunNotifier{n}
+	}
+	return &errorAlert{n: n, name: cfg.name, threshold: cfg.ErrorThreshold}
+}
+
+func (a *errorAlert) onFailure(ctx context.Context, problem string) {
+	if a == nil {
+		return
+	}
+	a.streak++
+	if a.streak < a.threshold || a.alerted {
+		return
+	}
+	a.alerted = true
+	a.send(ctx, fmt.Sprintf("terminator%s: %d consecutive failed checks, last: %s", a.label(), a.streak, problem))
+}
+
+// onBlocked alerts right away, without waiting for error_threshold: every
+// further check while blocked makes the block worse.
+func (a *errorAlert) onBlocked(ctx context.Context, problem string) {
+	if a == nil {
+		return
+	}
+	a.streak++
+	if a.alerted {
+		return
+	}
+	a.alerted = true
+	a.send(ctx, fmt.Sprintf("terminator%s: %s", a.label(), problem))
+}
+
+func (a *errorAlert) onRecovery(ctx context.Context) {
+	if a == nil {
+		return
+	}
+	if a.alerted {
+		a.send(ctx, fmt.Sprintf("terminator%s: recovered after %d failed checks", a.label(), a.streak))
+	}
+	a.streak = 0
+	a.alerted = false
+}
+
+func (a *errorAlert) label() string {
+	if a.name == "" {
+		return ""
+	}
+	return " [" + a.name + "]"
+}
+
+func (a *errorAlert) send(ctx context.Context, message string) {
+	if err := a.n.Notify(ctx, message); err != nil {
+		log.Printf("error webhook: %v", redactErr(err))
+	}
+}
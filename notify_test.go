@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	clock := newFakeClock()
+	d := newDedup(30*time.Minute, clock)
+
+	if !d.allow("a") {
+		t.Fatal("first message suppressed")
+	}
+	if !d.allow("b") {
+		t.Fatal("different message suppressed")
+	}
+	clock.Advance(29 * time.Minute)
+	if d.allow("a") {
+		t.Fatal("duplicate within the TTL allowed")
+	}
+	clock.Advance(time.Minute)
+	if !d.allow("a") {
+		t.Fatal("message suppressed after the TTL expired")
+	}
+
+	var off *dedup
+	if !off.allow("a") || !off.allow("a") {
+		t.Fatal("nil dedup suppressed a message")
+	}
+}
+
+// recordingNotifier keeps the messages it was asked to send.
+type recordingNotifier struct {
+	name string // "recording" when empty
+	sent []string
+}
+
+func (r *recordingNotifier) Name() string { return cmp.Or(r.name, "recording") }
+
+func (r *recordingNotifier) Notify(_ context.Context, message string) error {
+	r.sent = append(r.sent, message)
+	return nil
+}
+
+func TestNotifyCap(t *testing.T) {
+	rec := &recordingNotifier{}
+	s := &sniper{
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		notifiers: []Notifier{rec},
+		notifyCap: newNotifyCap(2),
+	}
+	for i, want := range []bool{true, true, false} {
+		if got := s.notifyVia(context.Background(), s.notifiers, "slots"); got != want {
+			t.Fatalf("notification %d sent = %v, want %v", i+1, got, want)
+		}
+	}
+	if len(rec.sent) != 3 || rec.sent[2] == "slots" {
+		t.Fatalf("sent %q, want two notifications and the cap message", rec.sent)
+	}
+
+	// Other messages don't use up the cap, and the cap message only goes
+	// to the notifiers the last appointment went to.
+	rec, other := &recordingNotifier{}, &recordingNotifier{name: "other"}
+	s.notifiers = []Notifier{rec, other}
+	s.notifyCap = newNotifyCap(1)
+	s.notify(context.Background(), (&Config{}).goneMessage())
+	if !s.notifyVia(context.Background(), []Notifier{rec}, "slots") {
+		t.Fatal("appointment after a gone message was capped")
+	}
+	if len(rec.sent) != 3 || len(other.sent) != 1 {
+		t.Errorf("sent %q and %q, want gone, slots and the cap message, and only gone to the other notifier", rec.sent, other.sent)
+	}
+
+	if ok, last := (*notifyCap)(nil).take(); !ok || last {
+		t.Error("nil cap should be unlimited")
+	}
+}
+
+func TestSuccessOnlyNotifiers(t *testing.T) {
+	rec, browser := &recordingNotifier{}, &recordingNotifier{name: "browser"}
+	s := &sniper{
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		notifiers: []Notifier{rec, browser},
+	}
+	s.notify(context.Background(), (&Config{}).goneMessage())
+	if len(rec.sent) != 1 || len(browser.sent) != 0 {
+		t.Fatalf("gone message: sent %q and %q to the browser, want it only in the text notifier", rec.sent, browser.sent)
+	}
+
+	rec.sent = nil
+	s.notifyCap = newNotifyCap(1)
+	s.notifyVia(context.Background(), s.notifiers, "slots")
+	if len(browser.sent) != 1 || len(rec.sent) != 2 {
+		t.Errorf("success with cap: browser got %q and text notifier %q, want the cap message only in the latter", browser.sent, rec.sent)
+	}
+}
+
+func TestWebhookBasicAuth(t *testing.T) {
+	var user, pass, team string
+	var ok bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok = r.BasicAuth()
+		team = r.Header.Get("X-Team")
+	}))
+	defer srv.Close()
+
+	n := &webhookNotifier{urls: []string{srv.URL}, method: http.MethodPut, username: "terminator", password: "s3cret", attempts: 1}
+	if err := n.Notify(context.Background(), "found"); err != nil {
+		t.Fatal(err)
+	}
+	if !ok || user != "terminator" || pass != "s3cret" {
+		t.Errorf("basic auth = %q/%q (present %v), want terminator/s3cret", user, pass, ok)
+	}
+
+	// The error alert and heartbeat often post to the same receiver.
+	cfg := &Config{ErrorWebhookURL: srv.URL, WebhookUsername: "terminator", WebhookPassword: "s3cret", WebhookHeaders: map[string]string{"X-Team": "a"}}
+	for _, n := range []Notifier{newErrorAlert(cfg, false).n, newHeartbeat(&Config{HeartbeatWebhookURL: srv.URL, WebhookUsername: "hb", WebhookPassword: "pw", WebhookHeaders: cfg.WebhookHeaders}, time.Hour, false).n} {
+		user, pass, ok = "", "", false
+		if err := n.Notify(context.Background(), "alert"); err != nil {
+			t.Fatal(err)
+		}
+		if !ok || user == "" || pass == "" || team != "a" {
+			t.Errorf("basic auth %q/%q (present %v), X-Team %q: want the webhook credentials and headers", user, pass, ok, team)
+		}
+	}
+}
+
+func TestDrainOutlivesCancel(t *testing.T) {
+	d := &drain{timeout: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	sendCtx, done := d.start(ctx)
+	cancel()
+	if err := sendCtx.Err(); err != nil {
+		t.Fatalf("send context cancelled with its parent: %v", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		done()
+	}()
+	if !d.wait() {
+		t.Error("wait gave up on a send that finished")
+	}
+
+	sendCtx, done = (&drain{timeout: 10 * time.Millisecond}).start(ctx)
+	defer done()
+	select {
+	case <-sendCtx.Done():
+	case <-time.After(time.Second):
+		t.Error("send context not cancelled after the drain timeout")
+	}
+}
+
+func TestBrowserNotifierDoesNotBlock(t *testing.T) {
+	// "sleep 5" stands in for a browser that takes its time to start.
+	n := &browserNotifier{tool: "sleep", url: "5"}
+	start := time.Now()
+	if err := n.Notify(context.Background(), "slots"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Notify took %s, want it to return right away", d)
+	}
+
+	missing := &browserNotifier{tool: "no-such-open-tool", url: "https://example.com/"}
+	if err := missing.Notify(context.Background(), "slots"); err == nil {
+		t.Error("want an error when the tool can't be started")
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 test\r\n")
+		var data strings.Builder
+		for inData := false; ; {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				got <- data.String()
+				fmt.Fprint(conn, "250 queued\r\n")
+			case inData:
+				data.WriteString(line)
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				fmt.Fprint(conn, "354 go ahead\r\n")
+			case strings.HasPrefix(line, "QUIT"):
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default: // EHLO, MAIL, RCPT
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+
+	host, _, _ := net.SplitHostPort(ln.Addr().String())
+	n := &emailNotifier{host: host, addr: ln.Addr().String(), from: "t@example.com", to: []string{"me@example.com"}}
+	if err := n.Notify(context.Background(), "slots"); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-got; !strings.Contains(msg, "Subject: Berlin appointment found") || !strings.Contains(msg, "slots") {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestEmailNotifierHungServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second) // never greets
+		}
+	}()
+
+	n := &emailNotifier{host: "127.0.0.1", addr: ln.Addr().String(), from: "t@example.com", to: []string{"me@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Notify(ctx, "slots"); err == nil {
+		t.Fatal("want an error from a server that never answers")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Notify took %s, want it bounded by the context", d)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// bookingPause is the --post-success-pause shared by all snipers: after a
+// notified success on any service, every service stops checking until it
+// ends, so the user can book without the site being hit or more
+// notifications arriving. A nil *bookingPause never pauses.
+type bookingPause struct {
+	d     time.Duration
+	clock Clock
+
+	mu    sync.Mutex
+	until time.Time
+}
+
+// newBookingPause returns nil when d is not positive.
+func newBookingPause(d time.Duration, clock Clock) *bookingPause {
+	if d <= 0 {
+		return nil
+	}
+	return &bookingPause{d: d, clock: clock}
+}
+
+// start pauses all snipers for d from now, extending a pause in progress.
+func (p *bookingPause) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = later(p.until, p.clock.Now().Add(p.d))
+}
+
+// end cancels the pause in progress, for --tui's "check now".
+func (p *bookingPause) end() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.until = time.Time{}
+}
+
+// remaining returns how long the pause in progress still lasts, or 0.
+func (p *bookingPause) remaining() time.Duration {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(p.until.Sub(p.clock.Now()), 0)
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"sync"
+	"time"
+)
+
+// preflightMessage is what --webhook-preflight sends, worded so receivers
+// can tell it from a real alert and drop it.
+const preflightMessage = "[TEST] terminator webhook preflight — this is not an appointment, please ignore"
+
+// preflightHeader is set on test requests to plain webhooks, for receivers
+// that filter by header rather than by text.
+const preflightHeader = "X-Terminator-Test"
+
+// preflightTimeout bounds the test request to one webhook.
+const preflightTimeout = 15 * time.Second
+
+// preflightTarget is one webhook to test.
+type preflightTarget struct {
+	label string // for the log, with the URL redacted
+	n     Notifier
+}
+
+// preflightTargets lists every configured webhook once: webhook_url(s),
+// including the per-service ones, error_webhook_url, heartbeat_webhook_url,
+// discord_webhook and slack_webhook. Each gets a single attempt, so a broken
+// one fails at once instead of after the retries.
+func preflightTargets(cfg *Config, services []*Config) []preflightTarget {
+	var targets []preflightTarget
+	seen := make(map[string]bool)
+	add := func(kind, u string, n Notifier) {
+		if seen[u] {
+			return
+		}
+		seen[u] = true
+		targets = append(targets, preflightTarget{label: kind + " " + redactURL(u), n: n})
+	}
+	for _, c := range services {
+		base := newWebhookNotifier(c)
+		if base == nil {
+			continue
+		}
+		for _, u := range c.WebhookURLs {
+			w := *base
+			w.urls = []string{u}
+			add("webhook", u, testWebhook(&w))
+		}
+	}
+	if u := cfg.ErrorWebhookURL; u != "" {
+		add("error webhook", u, testWebhook(cfg.plainWebhook(u)))
+	}
+	if u := cfg.HeartbeatWebhookURL; u != "" {
+		add("heartbeat webhook", u, testWebhook(cfg.plainWebhook(u)))
+	}
+	if u := cfg.DiscordWebhook; u != "" {
+		add("discord", u, &discordNotifier{webhookURL: u, serviceURL: cfg.ServiceURL})
+	}
+	if u := cfg.SlackWebhook; u != "" {
+		add("slack", u, &slackNotifier{webhookURL: u, serviceURL: cfg.ServiceURL})
+	}
+	return targets
+}
+
+// testWebhook makes w a single-attempt webhook that marks its requests with
+// preflightHeader.
+func testWebhook(w *webhookNotifier) *webhookNotifier {
+	w.attempts = 1
+	w.headers = maps.Clone(w.headers)
+	if w.headers == nil {
+		w.headers = make(map[string]string)
+	}
+	w.headers[preflightHeader] = "1"
+	return w
+}
+
+// webhookPreflight sends preflightMessage to every target in parallel, logs
+// each result and returns how many failed.
+func webhookPreflight(ctx context.Context, targets []preflightTarget) int {
+	if len(targets) == 0 {
+		log.Printf("webhook preflight: no webhooks configured")
+		return 0
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for _, t := range targets {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+			defer cancel()
+			err := t.n.Notify(ctx, preflightMessage)
+			if err == nil {
+				log.Printf("webhook preflight: %s ok", t.label)
+				return
+			}
+			log.Printf("webhook preflight: %s failed: %v", t.label, redactErr(err))
+			mu.Lock()
+			failed++
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return failed
+}
+
+// checkPreflightMode validates --webhook-preflight.
+func checkPreflightMode(mode string) error {
+	switch mode {
+	case "", "off", "warn", "strict":
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q (want off, warn or strict)", mode)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWebhookPreflight(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(preflightHeader) != "1" || !strings.Contains(string(body), "[TEST]") {
+			t.Errorf("test request not marked: header %q, body %s", r.Header.Get(preflightHeader), body)
+		}
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &Config{
+		WebhookURLs:     []string{srv.URL + "/ok"},
+		ErrorWebhookURL: srv.URL + "/broken",
+		webhookAttempts: 3,
+	}
+	svc := *cfg
+	svc.WebhookURLs = []string{srv.URL + "/ok", srv.URL + "/service"}
+	targets := preflightTargets(cfg, []*Config{cfg, &svc})
+	if len(targets) != 3 {
+		t.Fatalf("got %d targets, want 3 (shared webhook once, service webhook, error webhook)", len(targets))
+	}
+	if failed := webhookPreflight(context.Background(), targets); failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d requests, want 3: the 5xx must not be retried", n)
+	}
+	if err := checkPreflightMode("loud"); err == nil {
+		t.Error("want an error for an unknown mode")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// PreStep is one action run on the booking page before it is checked, for
+// services that ask for options on an intermediate form first.
+type PreStep struct {
+	Action   string `yaml:"action"` // click, wait or select
+	Selector string `yaml:"selector"`
+	Value    string `yaml:"value"` // the option value, for select
+}
+
+// validatePreSteps checks steps and returns a problem description, or "" if
+// they are all valid.
+func validatePreSteps(steps []PreStep) string {
+	for i, st := range steps {
+		switch {
+		case st.Action != "click" && st.Action != "wait" && st.Action != "select":
+			return fmt.Sprintf("step %d has unknown action %q (want click, wait or select)", i+1, st.Action)
+		case st.Selector == "":
+			return fmt.Sprintf("step %d has no selector", i+1)
+		case st.Action == "select" && st.Value == "":
+			return fmt.Sprintf("step %d selects no value", i+1)
+		}
+	}
+	return ""
+}
+
+// preStepsAction runs steps in order. Each waits for its element first, so
+// it is bounded by the check timeout rather than failing on slow pages.
+func preStepsAction(steps []PreStep) chromedp.Action {
+	var tasks chromedp.Tasks
+	for _, st := range steps {
+		switch st.Action {
+		case "click":
+			tasks = append(tasks, chromedp.Click(st.Selector, chromedp.ByQuery))
+		case "wait":
+			tasks = append(tasks, chromedp.WaitVisible(st.Selector, chromedp.ByQuery))
+		case "select":
+			// Setting the value alone doesn't fire the change event forms listen for.
+			sel, _ := json.Marshal(st.Selector)
+			tasks = append(tasks,
+				chromedp.SetValue(st.Selector, st.Value, chromedp.ByQuery),
+				chromedp.Evaluate(fmt.Sprintf(`document.querySelector(%s).dispatchEvent(new Event('change', {bubbles: true}))`, sel), nil),
+			)
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/chromedp"
+)
+
+// parseProxy validates a proxy URL of the form scheme://[user:pass@]host:port.
+// Chrome only supports credentials for http/https proxies.
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (want http, https or socks5)", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("proxy URL must include host and port")
+	}
+	if u.User != nil && u.Scheme == "socks5" {
+		return nil, fmt.Errorf("Chrome does not support SOCKS proxy authentication")
+	}
+	return u, nil
+}
+
+// proxyServer returns the proxy address without credentials, as passed to
+// Chrome's --proxy-server.
+func proxyServer(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// listenProxyAuth answers proxy authentication challenges in browserCtx with
+// the credentials from u. Fetch interception must be enabled in each check via
+// proxyAuthAction, which pauses every request; they are continued here.
+func listenProxyAuth(browserCtx context.Context, u *url.URL) {
+	user := u.User.Username()
+	pass, _ := u.User.Password()
+	chromedp.ListenTarget(browserCtx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *fetch.EventRequestPaused:
+			go chromedp.Run(browserCtx, fetch.ContinueRequest(e.RequestID))
+		case *fetch.EventAuthRequired:
+			resp := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+			if e.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+				resp = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: user,
+					Password: pass,
+				}
+			}
+			go chromedp.Run(browserCtx, fetch.ContinueWithAuth(e.RequestID, resp))
+		}
+	})
+}
+
+// proxyAuthAction enables the fetch interception listenProxyAuth relies on.
+// It is a no-op unless the proxy carries credentials.
+func proxyAuthAction(u *url.URL) chromedp.Action {
+	if u == nil || u.User == nil {
+		return chromedp.ActionFunc(func(context.Context) error { return nil })
+	}
+	return fetch.Enable().WithHandleAuthRequests(true)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// redactURL keeps only the scheme and host of rawURL. Webhook paths and
+// queries often carry the secret (Discord, Slack, Telegram's bot token), and
+// logs end up pasted into issues.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "***"
+	}
+	if u.User == nil && strings.Trim(u.Path, "/") == "" && u.RawQuery == "" && u.Fragment == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+// redactErr masks the URLs of any *url.Error in err's tree, as returned by
+// http.Client when a request fails.
+func redactErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	redacted := msg
+	for _, u := range errorURLs(err) {
+		redacted = strings.ReplaceAll(redacted, u, redactURL(u))
+	}
+	if redacted == msg {
+		return err
+	}
+	return errors.New(redacted)
+}
+
+// errorURLs collects the URLs of the *url.Errors in err's tree.
+func errorURLs(err error) []string {
+	if ue, ok := err.(*url.Error); ok {
+		return append([]string{ue.URL}, errorURLs(ue.Err)...)
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			return errorURLs(inner)
+		}
+	case interface{ Unwrap() []error }:
+		var urls []string
+		for _, inner := range e.Unwrap() {
+			urls = append(urls, errorURLs(inner)...)
+		}
+		return urls
+	}
+	return nil
+}
+
+// redactSecret masks a config value tagged secret:"true": URLs keep their
+// scheme and host, anything else is hidden entirely.
+func redactSecret(s string) string {
+	if strings.Contains(s, "://") {
+		return redactURL(s)
+	}
+	return "***"
+}
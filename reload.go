@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// reloadConfig re-reads the config file on SIGHUP and hands each sniper its
+// new per-service config. services are the configs the snipers started with,
+// in the same order; services are matched by name, and adding or removing
+// one needs a restart. The shared heartbeat hb is updated too. On error the
+// current config stays in effect.
+func reloadConfig(path string, applyFlags func(*Config), services []*Config, snipers []*sniper, hb *heartbeat) {
+	next, err := loadConfig(path)
+	if err != nil {
+		log.Printf("config: reload failed (%v) — keeping the current config", err)
+		return
+	}
+	applyFlags(next)
+
+	byName := make(map[string]*Config)
+	for _, c := range next.forServices() {
+		byName[c.name] = c
+	}
+	for i, s := range snipers {
+		name := services[i].name
+		c, ok := byName[name]
+		if !ok {
+			log.Printf("config: reload: service %q is no longer configured — restart to stop monitoring it", name)
+			continue
+		}
+		delete(byName, name)
+		s.reload(c)
+	}
+	for name := range byName {
+		log.Printf("config: reload: new service %q — restart to start monitoring it", name)
+	}
+	hb.update(next)
+	log.Printf("config: reloaded %s", path)
+}
+
+// setConfig makes c the sniper's config and rebuilds everything derived from
+// it. It runs on the sniper's own goroutine, or before the sniper starts.
+func (s *sniper) setConfig(c *Config) {
+	if s.cfg == nil || !maps.Equal(s.cfg.NotifierThrottles, c.NotifierThrottles) {
+		s.notifierThrottles = make(map[string]throttle)
+		for name, t := range c.NotifierThrottles {
+			if t.Always {
+				s.notifierThrottles[name] = alwaysThrottle{}
+			} else {
+				s.notifierThrottles[name] = newThrottle(t.Window, t.Cooldown, s.clock)
+			}
+		}
+	}
+	s.cfg = c
+	s.notifiers = newNotifiers(c)
+	s.webhook = nil
+	for i, n := range s.notifiers {
+		if s.dryRun {
+			n = dryRunNotifier{n}
+			s.notifiers[i] = n
+		}
+		if n.Name() == "webhook" {
+			s.webhook = n
+		}
+	}
+	alert := newErrorAlert(c, s.dryRun)
+	if alert != nil && s.errAlert != nil {
+		alert.streak, alert.alerted = s.errAlert.streak, s.errAlert.alerted
+	}
+	s.errAlert = alert
+
+	s.backoff.setBase(c.checkInterval(s.interval))
+}
+
+// reload queues c to replace the sniper's config before its next check.
+func (s *sniper) reload(c *Config) {
+	s.mu.Lock()
+	s.pending = c
+	s.mu.Unlock()
+}
+
+// applyReload swaps in a config queued by reload, if any, and logs which
+// settings changed.
+func (s *sniper) applyReload() {
+	s.mu.Lock()
+	c := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if c == nil {
+		return
+	}
+
+	changed := configChanges(s.cfg, c)
+	if len(changed) == 0 {
+		s.log.Info("config reloaded, nothing changed")
+		return
+	}
+	s.setConfig(c)
+	s.log.Info("config reloaded", "changed", strings.Join(changed, ", "))
+	if slices.Contains(changed, "proxy_url") || slices.Contains(changed, "proxies") {
+		s.log.Warn("proxy_url and proxies changes take effect after a restart")
+	}
+}
+
+// configChanges returns the YAML keys whose values differ between a and b.
+// Only keys are reported, so secrets never end up in the log.
+func configChanges(a, b *Config) []string {
+	var changed []string
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	t := va.Type()
+	for i := range t.NumField() {
+		key := yamlKey(t.Field(i))
+		if key == "" {
+			continue
+		}
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
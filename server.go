@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// serve runs an HTTP server for h on addr in the background until ctx is
+// cancelled. name prefixes its log lines.
+func serve(ctx context.Context, name, addr string, h http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: h}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		log.Printf("%s: listening on %s", name, addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("%s: server failed: %v", name, err)
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// snapshotTimeout bounds taking one screenshot.
+const snapshotTimeout = 15 * time.Second
+
+// siteState names what a check saw, a little finer than its outcome: a
+// known page is split into taken, maintenance and rate limited.
+func siteState(o outcome, p pageState, cfg *Config) string {
+	if o != outcomeKnown {
+		if o == outcomeSuccess {
+			return "available"
+		}
+		return o.String()
+	}
+	switch {
+	case strings.Contains(p.headline, cfg.MaintenanceHeadline):
+		return "maintenance"
+	case p.status == 429 || p.status == 403:
+		return "rate-limited"
+	default:
+		return "taken"
+	}
+}
+
+// snapshotOnChange logs a transition when the site state differs from the
+// previous check's and, with --snapshot-on-change, saves a screenshot of the
+// current page to s.screenshotDir. The first check only sets the baseline.
+func (s *sniper) snapshotOnChange(browserCtx context.Context, state string, at time.Time) {
+	prev := s.lastState
+	s.lastState = state
+	if prev == "" || prev == state {
+		return
+	}
+	if !s.snapshotChanges {
+		s.log.Info("state changed", "from", prev, "to", state)
+		return
+	}
+	ctx, cancel := context.WithTimeout(browserCtx, snapshotTimeout)
+	defer cancel()
+	var png []byte
+	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&png, 100)); err != nil {
+		s.log.Info("state changed", "from", prev, "to", state)
+		s.logf("screenshot: could not capture (%v)", err)
+		return
+	}
+	name := "change-" + at.Format("20060102-150405")
+	if s.cfg.name != "" {
+		name += "-" + s.cfg.name
+	}
+	path := filepath.Join(s.screenshotDir, name+"-"+state+".png")
+	if err := os.WriteFile(path, png, 0o644); err != nil {
+		s.log.Info("state changed", "from", prev, "to", state)
+		s.logf("screenshot: could not write %s (%v)", path, err)
+		return
+	}
+	s.log.Info("state changed", "from", prev, "to", state, "screenshot", path)
+}
@@ -0,0 +1,1139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+const mitteBtn = `#service_locationlist_checkboxgroup > fieldset > div:nth-child(1) > ul:nth-child(6) > li:nth-child(2) > div.listitem__footer > div > a`
+
+// openAppointmentPage moves from the service page to the booking page, either
+// by navigating to the configured appointment URL or by clicking the Mitte link.
+func openAppointmentPage(cfg *Config) chromedp.Action {
+	if cfg.AppointmentURL != "" {
+		return chromedp.Navigate(cfg.AppointmentURL)
+	}
+	return chromedp.Tasks{
+		chromedp.ScrollIntoView(mitteBtn, chromedp.ByQuery),
+		chromedp.Sleep(500 * time.Millisecond),
+		chromedp.Click(mitteBtn, chromedp.ByQuery),
+	}
+}
+
+// browseToAppointments follows openAppointmentPage from the service page.
+// With --direct it waits a fixed 2s; otherwise it dwells for a random time in
+// [dwellMin, dwellMax] and sends the service page as Referer, like a visitor
+// clicking through.
+func (s *sniper) browseToAppointments(cfg *Config) chromedp.Action {
+	if s.direct {
+		return chromedp.Tasks{
+			s.dwell(),
+			s.dismissConsent(cfg),
+			openAppointmentPage(cfg),
+		}
+	}
+	return chromedp.Tasks{
+		s.dwell(),
+		s.dismissConsent(cfg),
+		network.SetExtraHTTPHeaders(network.Headers{"Referer": cfg.ServiceURL}),
+		openAppointmentPage(cfg),
+		network.SetExtraHTTPHeaders(network.Headers{}),
+	}
+}
+
+// dwell waits on the service page: 2s with --direct, otherwise a random
+// time between dwellMin and dwellMax.
+func (s *sniper) dwell() chromedp.Action {
+	if s.direct {
+		return chromedp.Sleep(2 * time.Second)
+	}
+	d := s.dwellMin
+	if s.dwellMax > s.dwellMin {
+		d += rand.N(s.dwellMax - s.dwellMin)
+	}
+	return chromedp.Sleep(d)
+}
+
+// sniper holds the settings and state of the check loop.
+type sniper struct {
+	cfg               *Config      // replaced between checks on reload; see applyReload
+	log               *slog.Logger // carries the service name when monitoring several
+	clock             Clock
+	interval          time.Duration // --interval, used when the config sets no interval
+	backoff           *backoffState
+	throttle          throttle
+	alwaysCallWebhook bool
+	store             Store    // throttle state and recent outcomes; see newStore
+	jitter            float64  // fraction of the interval to randomize the wait by
+	metrics           *metrics // nil when --metrics-addr is unset
+	notifiers         []Notifier
+	webhook           Notifier // also in notifiers; nil when no webhook is configured
+	errAlert          *errorAlert
+	allocOpts         []chromedp.ExecAllocatorOption
+	maxErrors         int        // consecutive errors before the browser is restarted; 0 disables
+	proxies           []*url.URL // proxy_url and proxies, in rotation order
+	proxy             *url.URL   // the proxy in use, one of proxies; nil when none is configured
+	proxyFailures     int        // consecutive failed checks before switching to the next proxy
+	health            *health    // nil when --health-addr is unset
+	checkTimeout      time.Duration
+	captchaInterval   time.Duration // minimum wait after a bot challenge
+	blockedInterval   time.Duration // minimum wait after a WAF block page
+	maintInterval     time.Duration // wait after a maintenance page that names no end time
+	errorInterval     time.Duration // minimum wait after an error or unexpected page
+	direct            bool          // skip the randomized dwell and Referer; see browseToAppointments
+	dwellMin          time.Duration
+	dwellMax          time.Duration
+	audit             *auditLog // nil when --audit-log is unset
+	history           *history  // nil when --db is unset
+	heartbeat         *heartbeat
+	gaps              gapStats
+	gapsFile          string // empty disables persisting gaps
+	dryRun            bool
+	fetchSlots        bool // follow a day link on success and report its times
+	confirm           bool // re-check after confirmDelay before treating a success as real
+	confirmDelay      time.Duration
+	breaker           *circuitBreaker // nil when --breaker-threshold is 0
+	dedup             *dedup          // shared by all snipers; nil when --dedup-ttl is 0
+	notifyCap         *notifyCap      // shared by all snipers; nil when --max-notifications is 0
+	drain             *drain          // shared by all snipers; tracks notifications in flight
+	budget            *checkBudget    // shared by all snipers; nil when --max-checks-per-hour is 0
+	dashboard         *dashboard      // nil when --dashboard-addr is unset
+	tui               *tui            // shared by all snipers; nil without --tui
+	checkNow          chan struct{}   // a send ends the current wait early; nil without --tui
+	fastInterval      time.Duration   // interval right after slots were seen; 0 disables
+	fastWindow        time.Duration   // how long it takes to ease back from fastInterval
+	lastHit           time.Time       // when slots were last seen
+	notifyOnGone      bool            // send goneMessage when announced slots are gone again
+	announced         bool            // slots were notified and no "no slots" page has been seen since
+	maxTabs           int             // appointment_urls endpoints loaded at once
+	stop              func()          // with --exit-on-success, stops all snipers after the first success notification
+	startupDelay      time.Duration   // before the first check, from --startup-delay and --startup-jitter
+	debugDir          string          // where unexpected pages are saved; empty disables
+	snapshotChanges   bool            // --snapshot-on-change
+	screenshotDir     string          // where --snapshot-on-change saves screenshots
+	lastState         string          // siteState of the previous check; empty before the first
+	idleAfter         int             // "no slots" pages in a row before the idle ramp starts; 0 disables
+	idleStep          time.Duration   // added to the interval per further "no slots" page
+	idleMax           time.Duration   // cap of the idle ramp
+	idleStreak        int             // consecutive "no slots" pages so far
+	pause             *bookingPause   // shared by all snipers; nil without --post-success-pause
+	tracer            *tracer         // shared by all snipers; nil without --otel-endpoint
+	span              *span           // of the check in progress; nil without a tracer
+	pausedForBooking  bool            // waiting out the shared post-success pause
+	renderEvery       int             // with availability_url, render the page fully every this many checks
+	apiCookies        []*http.Cookie  // for availability_url, from the last full render; nil forces one
+	apiChecks         int             // checks since the last full render
+	userAgent         string          // of the current browser
+
+	mu      sync.Mutex
+	pending *Config // set by reload, applied before the next check
+
+	// notifierThrottles are the notifier_throttles, by notifier name. They
+	// are kept across reloads unless notifier_throttles changes.
+	notifierThrottles map[string]throttle
+
+	timing     docTiming     // network timing of the latest document, set by the network listener
+	lastStatus atomic.Int64  // latest document response status, set by the network listener
+	retryAfter atomic.Int64  // Retry-After of the latest document response, as a time.Duration; 0 when absent
+	holdOff    time.Duration // minimum wait before the next check, even after jitter
+}
+
+// preflightBrowser starts and closes a browser once, so a missing or broken
+// Chrome is reported at startup instead of as an error on every check.
+func preflightBrowser(ctx context.Context, opts []chromedp.ExecAllocatorOption) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
+	defer allocCancel()
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	defer browserCancel()
+	return chromedp.Run(browserCtx)
+}
+
+// startBrowser creates a fresh allocator and browser context under ctx and
+// registers the network listener on it. The returned func closes both.
+func (s *sniper) startBrowser(ctx context.Context) (context.Context, context.CancelFunc) {
+	ua := defaultUserAgent
+	if uas := s.cfg.UserAgents; len(uas) > 0 {
+		ua = uas[rand.IntN(len(uas))]
+	}
+	s.log.Debug("browser: starting", "user_agent", ua)
+	s.userAgent = ua
+	s.apiCookies = nil // from the previous browser
+	opts := append(slices.Clip(s.allocOpts), chromedp.UserAgent(ua))
+	if s.proxy != nil {
+		opts = append(opts, chromedp.ProxyServer(proxyServer(s.proxy)))
+		if len(s.proxies) > 1 {
+			s.log.Info("browser: using proxy", "proxy", s.proxy.Redacted())
+		}
+	}
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(log.Printf))
+
+	// Listeners live as long as the browser context, so register once rather than per check.
+	chromedp.ListenTarget(browserCtx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *network.EventResponseReceived:
+			s.logResponse(e)
+			if e.Type == network.ResourceTypeDocument {
+				s.lastStatus.Store(e.Response.Status)
+				ra, _ := parseRetryAfter(headerValue(e.Response.Headers, "Retry-After"), s.clock.Now())
+				s.retryAfter.Store(int64(ra))
+				s.timing.responded(e)
+			}
+		case *network.EventLoadingFinished:
+			s.timing.finished(e)
+		}
+	})
+	if s.proxy != nil && s.proxy.User != nil {
+		listenProxyAuth(browserCtx, s.proxy)
+	}
+
+	return browserCtx, func() {
+		browserCancel()
+		allocCancel()
+	}
+}
+
+// logResponse logs document and XHR/fetch responses at debug level, to see
+// every request behind a check rather than just the status of the last one.
+func (s *sniper) logResponse(e *network.EventResponseReceived) {
+	switch e.Type {
+	case network.ResourceTypeDocument, network.ResourceTypeXHR, network.ResourceTypeFetch:
+		s.log.Debug("response", "type", strings.ToLower(e.Type.String()), "status", e.Response.Status, "mime", e.Response.MimeType, "url", e.Response.URL)
+	}
+}
+
+// nextProxy moves on to the next proxy in the rotation and reports whether
+// it changed. The browser must be restarted for it to take effect, since
+// Chrome takes the proxy on its command line.
+func (s *sniper) nextProxy() bool {
+	if len(s.proxies) < 2 {
+		return false
+	}
+	next := s.proxies[(slices.Index(s.proxies, s.proxy)+1)%len(s.proxies)]
+	s.log.Warn("rotating proxy", "from", s.proxy.Redacted(), "to", next.Redacted())
+	s.proxy = next
+	return true
+}
+
+// logf logs a formatted line through s.log, so it carries the service name.
+func (s *sniper) logf(format string, args ...any) {
+	s.log.Info(fmt.Sprintf(format, args...))
+}
+
+// notify sends a message that isn't about an appointment through every
+// notifier that delivers text. It doesn't count against --max-notifications.
+func (s *sniper) notify(ctx context.Context, message string) {
+	s.send(ctx, messageNotifiers(s.notifiers), message)
+}
+
+// notifyVia sends an appointment message through the given notifiers and
+// reports whether it was sent; --max-notifications can stop it. The notice
+// that the cap is reached goes to the text notifiers among them.
+func (s *sniper) notifyVia(ctx context.Context, notifiers []Notifier, message string) bool {
+	ok, last := s.notifyCap.take()
+	if !ok {
+		s.log.Info("notification suppressed", "reason", "max notifications", "max_notifications", s.notifyCap.max)
+		return false
+	}
+	s.send(ctx, notifiers, message)
+	if last {
+		s.log.Warn("notification cap reached, further appointments are only logged", "max_notifications", s.notifyCap.max)
+		s.send(ctx, messageNotifiers(notifiers), fmt.Sprintf("terminator: notification cap of %d reached — further appointments will only be logged", s.notifyCap.max))
+	}
+	return true
+}
+
+// send delivers message through notifiers, logging failures. A shutdown
+// while sending doesn't cut the message off; see drain.
+func (s *sniper) send(ctx context.Context, notifiers []Notifier, message string) {
+	ns := s.span.child("notify")
+	ns.set("notifiers", len(notifiers))
+	defer ns.finish()
+	ctx, done := s.drain.start(ctx)
+	defer done()
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, message); err != nil {
+			s.logf("%s: %v", n.Name(), redactErr(err))
+		}
+	}
+}
+
+// allowedNotifiers returns the notifiers a success should go to: those with
+// their own throttle if it lets the success through, the others if the
+// global throttle did (global).
+func (s *sniper) allowedNotifiers(global bool) []Notifier {
+	var ns []Notifier
+	for _, n := range s.notifiers {
+		t, own := s.notifierThrottles[n.Name()]
+		switch {
+		case own && t.onSuccess(), !own && global:
+			ns = append(ns, n)
+		case own:
+			s.log.Info("notification suppressed", "reason", "throttle", "notifier", n.Name(), "throttle", t.String())
+		}
+	}
+	return ns
+}
+
+// throttleFailure tells the global and all per-notifier throttles about a
+// check that didn't find slots.
+func (s *sniper) throttleFailure() {
+	s.throttle.onFailure()
+	for _, t := range s.notifierThrottles {
+		t.onFailure()
+	}
+}
+
+// withJitter returns d shifted by a uniformly random offset in
+// [-jitter*d, +jitter*d], never less than zero. A jitter of 0 returns d.
+func withJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	band := jitter * float64(d)
+	j := d + time.Duration((rand.Float64()*2-1)*band)
+	if j < 0 {
+		return 0
+	}
+	return j
+}
+
+// headerValue returns the value of the named header, matched
+// case-insensitively since HTTP/2 responses use lowercase names.
+func headerValue(h network.Headers, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			s, _ := v.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// parseRetryAfter parses a Retry-After value, either delay-seconds or an
+// HTTP date, into a wait from now. It reports false when v is empty or
+// unparseable.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
+
+// outcome classifies the result of one check.
+type outcome int
+
+const (
+	outcomeError      outcome = iota // the page could not be loaded
+	outcomeSuccess                   // slots are available
+	outcomeKnown                     // a known "no slots" page: taken, maintenance or rate limited
+	outcomeCaptcha                   // a bot challenge
+	outcomeUnexpected                // a page we don't recognize
+	outcomeBlocked                   // a WAF block page
+)
+
+func (o outcome) String() string {
+	switch o {
+	case outcomeSuccess:
+		return "success"
+	case outcomeKnown:
+		return "known"
+	case outcomeCaptcha:
+		return "captcha"
+	case outcomeUnexpected:
+		return "unexpected"
+	case outcomeBlocked:
+		return "blocked"
+	default:
+		return "error"
+	}
+}
+
+// pageState is what one check read from the booking page.
+type pageState struct {
+	status     int64
+	retryAfter time.Duration // from the Retry-After header; 0 when absent
+	bodyID     string
+	url        string
+	headline   string
+	headlineBy string        // the headline_selectors entry the headline came from
+	hints      string        // see pageHintsJS
+	ttfb       time.Duration // request start to response headers; 0 when unknown
+	loadTime   time.Duration // request start to fully loaded; 0 when unknown
+	dayLinks   []string
+	endpoint   string // the appointment_urls entry p was read from; empty without mirrors
+	html       string // only read with --debug-dir
+	text       string // the text success_text matches, only read when it is set
+	textBy     string // the selector text came from
+	api        bool   // read from availability_url; success_text doesn't apply
+}
+
+// isSuccessPage reports whether p shows available slots: by its body id, and
+// by its text when success_text is set.
+func (cfg *Config) isSuccessPage(p pageState) bool {
+	if p.status < 200 || p.status > 299 {
+		return false
+	}
+	byBodyID := slices.Contains(cfg.SuccessBodyIDs, p.bodyID)
+	if p.api {
+		return byBodyID
+	}
+	return cfg.SuccessText.decide(byBodyID, p.text)
+}
+
+// loadPage walks from the service page to the booking page and reads its state.
+func (s *sniper) loadPage(browserCtx context.Context) (pageState, error) {
+	cfg := s.cfg
+	var p pageState
+	s.lastStatus.Store(0)
+	s.retryAfter.Store(0)
+	s.timing.reset()
+
+	if s.useAPI() {
+		return s.loadAPI(browserCtx)
+	}
+	checkCtx, cancelCheck := context.WithTimeout(browserCtx, s.checkTimeout)
+	defer cancelCheck()
+	if cfg.AvailabilityURL != "" {
+		defer s.saveAPICookies(browserCtx)
+	}
+	if len(cfg.AppointmentURLs) > 0 {
+		return s.loadMirrors(checkCtx)
+	}
+	err := chromedp.Run(checkCtx,
+		network.Enable(),
+		proxyAuthAction(s.proxy),
+		chromedp.Evaluate(`Object.defineProperty(navigator, 'webdriver', {get: () => undefined})`, nil),
+		s.span.traced("navigate service page", chromedp.Navigate(cfg.ServiceURL)),
+		s.span.traced("open appointment page", s.browseToAppointments(cfg)),
+		s.span.traced("pre steps", preStepsAction(cfg.PreSteps)),
+		s.span.traced("wait ready", waitReady(cfg)),
+		s.dismissConsent(cfg),
+		s.span.traced("read page", readPage(&p, cfg, s.debugDir != "")),
+	)
+	if err != nil {
+		return pageState{}, err
+	}
+	p.status = s.lastStatus.Load()
+	p.retryAfter = time.Duration(s.retryAfter.Load())
+	p.ttfb, p.loadTime = s.timing.get()
+	return p, nil
+}
+
+// waitReady waits until cfg.ReadySelector is in the DOM, for at most
+// cfg.ReadyTimeout. Timing out isn't an error: the page is read as it is and
+// classified as unexpected if it really isn't rendered.
+func waitReady(cfg *Config) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		waitCtx, cancel := context.WithTimeout(ctx, cfg.ReadyTimeout)
+		defer cancel()
+		err := chromedp.WaitReady(cfg.ReadySelector, chromedp.ByQuery).Do(waitCtx)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return nil
+	})
+}
+
+// selectorTextsJS returns, for each selector of the JSON list it is
+// formatted with, the visible texts of the elements it matches in document
+// order. A selector the browser rejects matches nothing.
+const selectorTextsJS = `(sels => sels.map(sel => {
+	try { return Array.from(document.querySelectorAll(sel), el => (el.innerText || "").trim()); }
+	catch (e) { return []; }
+}))(%s)`
+
+// firstText returns the first of sels that matched an element with text, and
+// that text; both are empty if none did. found holds the texts per selector,
+// as returned by selectorTextsJS.
+func firstText(sels []string, found [][]string) (sel, text string) {
+	for i, texts := range found {
+		for _, t := range texts {
+			if t != "" && i < len(sels) {
+				return sels[i], t
+			}
+		}
+	}
+	return "", ""
+}
+
+// readFirstText reads firstText of sels on the open page into sel and text.
+func readFirstText(sels []string, sel, text *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		js, _ := json.Marshal(sels)
+		var found [][]string
+		if err := chromedp.Evaluate(fmt.Sprintf(selectorTextsJS, js), &found).Do(ctx); err != nil {
+			return err
+		}
+		*sel, *text = firstText(sels, found)
+		return nil
+	})
+}
+
+// readPage reads the open booking page into p, including the text success_text
+// is matched against when it is set and the HTML when withHTML is set. The status, Retry-After
+// and timing come from the network events instead.
+func readPage(p *pageState, cfg *Config, withHTML bool) chromedp.Action {
+	tasks := chromedp.Tasks{
+		chromedp.Evaluate("document.body.id", &p.bodyID),
+		chromedp.Evaluate("window.location.href", &p.url),
+		readFirstText(cfg.HeadlineSelectors, &p.headlineBy, &p.headline),
+		chromedp.Evaluate(bookableLinksJS, &p.dayLinks),
+		chromedp.Evaluate(pageHintsJS, &p.hints),
+	}
+	if cfg.SuccessText.active() {
+		tasks = append(tasks, readFirstText(cfg.successTextSelectors(), &p.textBy, &p.text))
+	}
+	if withHTML {
+		tasks = append(tasks, chromedp.Evaluate("document.documentElement.outerHTML", &p.html))
+	}
+	return tasks
+}
+
+// checkOnce runs a single check: it loads the page, classifies it, sends
+// notifications as allowed by the throttle, and records the result. It returns
+// the outcome and how long to wait before the next check.
+func (s *sniper) checkOnce(ctx, browserCtx context.Context) (outcome, time.Duration) {
+	s.applyReload()
+	s.holdOff = 0
+	s.breaker.beforeCheck()
+	cfg, backoff, throttle := s.cfg, s.backoff, s.throttle
+	start := s.clock.Now()
+	s.log.Info("--- checking appointments ---")
+	span := s.tracer.root("check")
+	s.span = span
+	defer func() {
+		span.finish()
+		s.span = nil
+	}()
+
+	var (
+		o          outcome
+		retryEvery time.Duration
+		problem    string // what went wrong, for error/captcha/unexpected outcomes
+	)
+	p, err := s.loadPage(browserCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return outcomeError, 0
+		}
+		o = outcomeError
+		class := classifyError(err, 0)
+		retryEvery = max(backoff.onError(class), s.errorInterval)
+		problem = class.String() + ": " + err.Error()
+		s.log.Error("check failed", "outcome", o.String(), "class", class.String(), "err", err, "retry_in", retryEvery.String())
+		s.throttleFailure()
+	} else {
+		s.metrics.setLastStatus(p.status)
+		page := []any{"status", p.status, "body_id", p.bodyID, "url", p.url}
+		if p.headline != "" {
+			page = append(page, "headline", p.headline)
+			s.log.Debug("headline found", "selector", p.headlineBy, "headline", p.headline)
+		} else if !p.api {
+			s.log.Debug("no headline found", "selectors", strings.Join(cfg.HeadlineSelectors, ", "))
+		}
+		if cfg.SuccessText.active() && !p.api {
+			s.log.Debug("success_text matched against", "selector", p.textBy, "selectors", strings.Join(cfg.successTextSelectors(), ", "))
+		}
+		if p.loadTime > 0 {
+			page = append(page, "ttfb", p.ttfb.Round(time.Millisecond).String(), "load", p.loadTime.Round(time.Millisecond).String())
+			s.metrics.observePageTiming(p.ttfb, p.loadTime)
+		}
+		s.log.Info("page loaded", page...)
+
+		cs := span.child("classify")
+		o, retryEvery, problem = s.classify(ctx, browserCtx, p)
+		cs.set("outcome", o.String())
+		cs.finish()
+	}
+
+	state := siteState(o, p, cfg)
+	retryEvery = s.idleInterval(state == "taken", retryEvery)
+	if cooldown := s.breaker.record(o == outcomeError || p.status >= 500); cooldown > 0 {
+		s.holdOff = max(s.holdOff, cooldown)
+		retryEvery = max(retryEvery, cooldown)
+	}
+
+	span.set("service", cfg.name)
+	span.set("outcome", o.String())
+	span.set("http.status", p.status)
+	span.set("body_id", p.bodyID)
+	span.set("retry_in", retryEvery.String())
+	span.fail(err)
+	s.metrics.observeCheck(o.String(), s.clock.Now().Sub(start))
+	s.snapshotOnChange(browserCtx, state, start)
+	entry := auditEntry{
+		Time:     start,
+		Service:  cfg.name,
+		Outcome:  o.String(),
+		Status:   p.status,
+		BodyID:   p.bodyID,
+		URL:      p.url,
+		Headline: p.headline,
+		Error:    problem,
+	}
+	s.audit.record(entry)
+	if err := s.store.RecordOutcome(entry); err != nil {
+		s.logf("state: could not record outcome (%v)", err)
+	}
+	s.dashboard.record(entry, throttle.String())
+	s.tui.record(entry, throttle.String())
+	if o == outcomeSuccess {
+		if err := s.history.record(start, cfg.name, p.status, p.url, parseAvailableDates(p.dayLinks)); err != nil {
+			s.logf("db: could not record success (%v)", err)
+		}
+	}
+	switch {
+	case o == outcomeBlocked:
+		s.errAlert.onBlocked(ctx, problem)
+	case problem != "":
+		s.errAlert.onFailure(ctx, problem)
+	default:
+		s.errAlert.onRecovery(ctx)
+	}
+	s.heartbeat.checked(p.status)
+
+	if err := s.store.SaveThrottle(throttle); err != nil {
+		s.logf("state: could not save throttle (%v) — persistence disabled", err)
+		s.store = &memStore{}
+	}
+	if len(s.notifierThrottles) > 0 {
+		if err := s.store.SaveNotifierThrottles(s.notifierThrottles); err != nil {
+			s.logf("state: could not save notifier throttles (%v) — persistence disabled", err)
+			s.store = &memStore{}
+		}
+	}
+	return o, retryEvery
+}
+
+// classify decides the outcome of a loaded page, acts on it, and returns the
+// outcome, the wait before the next check and, for failures, a description.
+func (s *sniper) classify(ctx, browserCtx context.Context, p pageState) (o outcome, retryEvery time.Duration, problem string) {
+	cfg, backoff, throttle := s.cfg, s.backoff, s.throttle
+
+	isWartung := strings.Contains(p.headline, cfg.MaintenanceHeadline)
+	known     := p.status == 429 || p.status == 403 || p.bodyID == cfg.TakenBodyID || isWartung
+	success   := cfg.isSuccessPage(p)
+	captcha   := matchMarker(cfg.CaptchaMarkers, p.bodyID, p.hints, p.headline)
+
+	// A success body id whose text says otherwise is the "no slots" page.
+	if !success && cfg.SuccessText.active() && slices.Contains(cfg.SuccessBodyIDs, p.bodyID) {
+		known = true
+	}
+
+	if success && captcha == "" && s.confirm {
+		if confirmed, ok := s.confirmSuccess(ctx, browserCtx); ok {
+			p = confirmed
+		} else {
+			success, known = false, true
+		}
+	}
+
+	if success && cfg.dates.active() {
+		dates := parseAvailableDates(p.dayLinks)
+		switch {
+		case len(dates) == 0:
+			s.log.Warn("could not parse available dates — notifying regardless of date range")
+		case !cfg.dates.match(dates, s.clock.Now()):
+			s.log.Info("slots available, none within the configured date range", "days", len(dates))
+			success, known = false, true
+		}
+	}
+
+	// A WAF block page, or a challenge served with 403, means we are being
+	// blocked rather than merely challenged.
+	blocked := matchMarker(cfg.BlockMarkers, p.bodyID, p.hints, p.headline)
+	if blocked == "" && p.status == 403 && captcha != "" {
+		blocked = "403 challenge"
+	}
+	if success {
+		blocked = ""
+	}
+
+	// Rate limiting and unexpected pages count as failures for backoff;
+	// any other completed check resets the interval. Bot challenges
+	// wait at least --captcha-interval, block pages --blocked-interval,
+	// unexpected pages --error-interval.
+	class := classifyError(nil, p.status)
+	switch {
+	case blocked != "":
+		retryEvery = max(backoff.onFailure(), s.blockedInterval)
+	case captcha != "":
+		retryEvery = max(backoff.onFailure(), s.captchaInterval)
+	case success || (known && p.status != 429 && p.status != 403):
+		if success {
+			s.markHit()
+		}
+		retryEvery = s.smartInterval(backoff.onSuccess())
+	case !known:
+		retryEvery = max(backoff.onError(class), s.errorInterval)
+	default:
+		retryEvery = backoff.onFailure()
+	}
+	if p.status == 429 && p.retryAfter > 0 {
+		s.holdOff = p.retryAfter
+		retryEvery = max(retryEvery, p.retryAfter)
+		s.log.Info("rate limited, honoring Retry-After", "retry_after", p.retryAfter.String())
+	}
+	if isWartung && !success && captcha == "" && blocked == "" {
+		wait := s.maintenanceWait(browserCtx, p.headline)
+		s.holdOff = max(s.holdOff, wait)
+		retryEvery = max(retryEvery, wait)
+	}
+
+	switch {
+	case blocked != "":
+		o = outcomeBlocked
+		problem = fmt.Sprintf("blocked by the site's firewall (marker %q)", blocked)
+		s.log.Warn("blocked by WAF", "outcome", o.String(), "marker", blocked, "status", p.status, "retry_in", retryEvery.String())
+		s.throttleFailure()
+
+	case captcha != "":
+		o = outcomeCaptcha
+		problem = fmt.Sprintf("bot challenge detected (marker %q)", captcha)
+		s.log.Warn("bot challenge detected", "outcome", o.String(), "marker", captcha, "retry_in", retryEvery.String())
+		s.throttleFailure()
+
+	case success:
+		o = outcomeSuccess
+		avail := availability(p.dayLinks)
+		found := []any{"outcome", o.String(), "availability", avail}
+		if p.endpoint != "" {
+			found = append(found, "endpoint", p.endpoint)
+		}
+		s.log.Info("!!! APPOINTMENT FOUND — slots may be available !!!", found...)
+		distinct := throttle.onSuccess()
+		if distinct {
+			s.recordSighting()
+		}
+		notifiers := s.allowedNotifiers(distinct)
+		if len(notifiers) == 0 {
+			s.log.Info("notification suppressed", "reason", "throttle", "throttle", throttle.String())
+		} else if cfg.inQuietHours(s.clock.Now()) {
+			s.log.Info("notification suppressed", "reason", "quiet hours", "quiet_hours", cfg.QuietHoursStart+"–"+cfg.QuietHoursEnd)
+		} else {
+			msg := cfg.successMessage(messageData{
+				URL:          cfg.ServiceURL,
+				Service:      cfg.name,
+				Headline:     p.headline,
+				Status:       p.status,
+				Time:         s.clock.Now(),
+				Dates:        formatDates(parseAvailableDates(p.dayLinks)),
+				Availability: avail,
+				Endpoint:     p.endpoint,
+			})
+			if s.fetchSlots {
+				msg = s.withSlots(browserCtx, p, msg)
+			}
+			switch {
+			case !s.dedup.allow(msg):
+				s.log.Info("notification suppressed", "reason", "duplicate", "dedup_ttl", s.dedup.ttl.String())
+			case s.notifyVia(ctx, notifiers, msg):
+				s.runSuccessCommand(ctx, msg)
+				s.announced = true
+				if s.pause != nil {
+					// Leave the site alone while the user books; the other
+					// services hold off too, see snipe.
+					s.pause.start()
+					retryEvery = max(retryEvery, s.pause.remaining())
+					s.holdOff = max(s.holdOff, s.pause.remaining())
+					s.pausedForBooking = true
+					s.log.Info("notified, pausing checks", "pause", s.pause.d.String())
+				}
+				if s.stop != nil {
+					s.log.Info("success notification sent, shutting down")
+					s.stop()
+				}
+			}
+		}
+
+	case known:
+		o = outcomeKnown
+		s.log.Info("no slots available", "outcome", o.String(), "retry_in", retryEvery.String())
+		s.throttleFailure()
+		if s.announced {
+			s.announced = false
+			if s.notifyOnGone && !cfg.inQuietHours(s.clock.Now()) {
+				s.log.Info("slots gone, notifying")
+				s.notify(ctx, cfg.goneMessage())
+			}
+		}
+		s.callWebhookAlways(ctx)
+
+	default:
+		o = outcomeUnexpected
+		problem = fmt.Sprintf("unexpected page (status=%d body.id=%q)", p.status, p.bodyID)
+		s.log.Warn("unexpected page", "outcome", o.String(), "class", class.String(), "body_id", p.bodyID, "retry_in", retryEvery.String())
+		s.dumpPage(p, s.clock.Now())
+		s.throttleFailure()
+		s.callWebhookAlways(ctx)
+	}
+	return o, retryEvery, problem
+}
+
+// confirmSuccess loads the page again after --confirm-delay and reports
+// whether it still shows slots, returning the fresh page if so.
+func (s *sniper) confirmSuccess(ctx, browserCtx context.Context) (pageState, bool) {
+	s.log.Info("slots detected, confirming", "attempt", 1, "confirm_in", s.confirmDelay.String())
+	select {
+	case <-ctx.Done():
+		return pageState{}, false
+	case <-s.clock.After(s.confirmDelay):
+	}
+	// The re-check counts against the budget but is not delayed by it; the
+	// next regular check waits longer instead.
+	s.budget.reserve()
+	p, err := s.loadPage(browserCtx)
+	switch {
+	case err != nil:
+		s.log.Warn("confirmation check failed, not notifying", "attempt", 2, "err", err)
+		return pageState{}, false
+	case !s.cfg.isSuccessPage(p):
+		s.log.Info("slots gone on confirmation, not notifying", "attempt", 2, "status", p.status, "body_id", p.bodyID)
+		return pageState{}, false
+	}
+	s.log.Info("slots confirmed", "attempt", 2)
+	return p, true
+}
+
+// withSlots opens the first bookable day that passes the date filter and
+// appends its available times to msg. On failure msg is returned unchanged.
+func (s *sniper) withSlots(browserCtx context.Context, p pageState, msg string) string {
+	day, times, err := s.loadSlots(browserCtx, p)
+	if err != nil {
+		s.log.Warn("could not fetch time slots", "err", err)
+		return msg
+	}
+	if len(times) == 0 {
+		s.log.Warn("no time slots found on the time selection page")
+		return msg
+	}
+	date := day.In(berlinLocation()).Format("Mon 2 Jan")
+	s.log.Info("time slots found", "day", date, "times", strings.Join(times, " "))
+	return fmt.Sprintf("%s — %s: %s", msg, date, strings.Join(times, ", "))
+}
+
+// loadSlots navigates from the calendar to a day's time selection page and
+// returns the day and its bookable HH:MM times.
+func (s *sniper) loadSlots(browserCtx context.Context, p pageState) (time.Time, []string, error) {
+	var day time.Time
+	var href string
+	now := s.clock.Now()
+	for _, l := range p.dayLinks {
+		if d := parseAvailableDates([]string{l}); len(d) == 1 && s.cfg.dates.accepts(d[0], now) {
+			day, href = d[0], l
+			break
+		}
+	}
+	if href == "" {
+		return day, nil, errors.New("no bookable day link on the calendar")
+	}
+	base, err := url.Parse(p.url)
+	if err != nil {
+		return day, nil, err
+	}
+	target, err := base.Parse(href)
+	if err != nil {
+		return day, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(browserCtx, s.checkTimeout)
+	defer cancel()
+	var labels []string
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(target.String()),
+		chromedp.Evaluate(timeSlotsJS, &labels),
+	)
+	return day, parseTimeSlots(labels), err
+}
+
+// recordSighting adds a distinct appointment sighting to the gap statistics,
+// logs and exports them, and persists them when a state file is in use.
+func (s *sniper) recordSighting() {
+	s.gaps.add(s.clock.Now())
+	n, mean, minGap := s.gaps.summary()
+	if n > 0 {
+		s.log.Info("time between appointments", "gaps", n, "mean", mean.Round(time.Minute).String(), "min", minGap.Round(time.Minute).String())
+		s.metrics.setGaps(s.cfg.name, n, mean, minGap)
+	}
+	if s.gapsFile != "" {
+		if err := s.gaps.save(s.gapsFile); err != nil {
+			s.logf("state: could not save %s (%v) — gap persistence disabled", s.gapsFile, err)
+			s.gapsFile = ""
+		}
+	}
+}
+
+// callWebhookAlways calls the webhook on a non-success check when
+// --always-call-webhook is set.
+func (s *sniper) callWebhookAlways(ctx context.Context) {
+	if !s.alwaysCallWebhook || s.webhook == nil {
+		return
+	}
+	if err := s.webhook.Notify(ctx, s.cfg.message()); err != nil {
+		s.logf("webhook: %v", redactErr(err))
+	}
+}
+
+// waitStartup waits s.startupDelay before the first check. It returns false
+// if ctx was cancelled in the meantime.
+func (s *sniper) waitStartup(ctx context.Context) bool {
+	if s.startupDelay <= 0 {
+		return true
+	}
+	s.log.Info("delaying first check", "delay", s.startupDelay.Round(time.Second).String())
+	s.health.checked(s.cfg.name, s.startupDelay)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-s.clock.After(s.startupDelay):
+		return true
+	}
+}
+
+// snipe runs checks until ctx is cancelled, restarting the browser after
+// s.maxErrors consecutive errors and on a different proxy after
+// s.proxyFailures consecutive failed checks. With --tui, a send on s.checkNow
+// ends the wait for the next check early, and pausing holds the loop before it.
+func (s *sniper) snipe(ctx context.Context) {
+	if last := s.store.RecentOutcomes(1); len(last) == 1 {
+		s.log.Info("resuming", "last_outcome", last[0].Outcome, "last_check", last[0].Time.Format(time.DateTime))
+	}
+	browserCtx, closeBrowser := s.startBrowser(ctx)
+	defer func() { closeBrowser() }()
+	restartBrowser := func() {
+		closeBrowser()
+		browserCtx, closeBrowser = s.startBrowser(ctx)
+	}
+
+	consecutiveErrors, proxyFailures := 0, 0
+	for {
+		if s.tui.paused() {
+			s.health.pause(s.cfg.name)
+		}
+		if !s.tui.waitResumed(ctx, s.checkNow) {
+			return
+		}
+		s.health.resume(s.cfg.name)
+		if wait := s.pause.remaining(); wait > 0 {
+			// Another service found slots and paused everything.
+			if !s.pausedForBooking {
+				s.log.Info("pausing checks while an appointment is booked", "resume_in", wait.Round(time.Second).String())
+			}
+			s.pausedForBooking = true
+			s.health.checked(s.cfg.name, wait)
+			s.tui.scheduled(s.cfg.name, s.clock.Now().Add(wait))
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(wait):
+			case <-s.checkNow:
+				s.pause.end()
+			}
+		}
+		if s.pausedForBooking {
+			s.pausedForBooking = false
+			s.log.Info("post-success pause over, resuming checks")
+		}
+		s.applyReload()
+		if wait := s.cfg.untilActive(s.clock.Now()); wait > 0 {
+			// Close the browser overnight rather than keep it idle.
+			s.log.Info("outside active hours, pausing", "active_hours", s.cfg.ActiveHoursStart+"–"+s.cfg.ActiveHoursEnd, "resume_in", wait.Round(time.Minute).String())
+			closeBrowser()
+			s.health.checked(s.cfg.name, wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(wait):
+			}
+			browserCtx, closeBrowser = s.startBrowser(ctx)
+			continue
+		}
+
+		if wait := s.budget.reserve(); wait > 0 {
+			s.log.Info("check budget exhausted, waiting", "max_checks_per_hour", s.budget.perHour, "wait", wait.Round(time.Second).String())
+			s.health.checked(s.cfg.name, wait)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(wait):
+			}
+		}
+
+		o, retryEvery := s.checkOnce(ctx, browserCtx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if o == outcomeError || o == outcomeCaptcha || o == outcomeUnexpected || o == outcomeBlocked {
+			proxyFailures++
+		} else {
+			proxyFailures = 0
+		}
+		if o != outcomeError {
+			consecutiveErrors = 0
+		} else {
+			consecutiveErrors++
+		}
+		switch {
+		case s.proxyFailures > 0 && proxyFailures >= s.proxyFailures && s.nextProxy():
+			restartBrowser()
+			consecutiveErrors, proxyFailures = 0, 0
+		case s.maxErrors > 0 && consecutiveErrors >= s.maxErrors:
+			s.log.Warn("restarting browser", "consecutive_errors", consecutiveErrors)
+			restartBrowser()
+			consecutiveErrors = 0
+		}
+
+		wait := max(withJitter(retryEvery, s.jitter), s.holdOff)
+		s.health.checked(s.cfg.name, wait)
+		s.tui.scheduled(s.cfg.name, s.clock.Now().Add(wait))
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(wait):
+		case <-s.checkNow:
+			s.pause.end()
+		}
+	}
+}
+
+// markHit records that slots were just seen, starting a --fast-window.
+func (s *sniper) markHit() {
+	if s.fastInterval > 0 && s.fastWindow > 0 {
+		s.log.Info("slots seen, checking faster", "interval", s.fastInterval.String(), "for", s.fastWindow.String())
+	}
+	s.lastHit = s.clock.Now()
+}
+
+// smartInterval shortens base to s.fastInterval right after slots were seen,
+// since they tend to appear in bursts, and eases back to base linearly over
+// s.fastWindow.
+func (s *sniper) smartInterval(base time.Duration) time.Duration {
+	if s.fastInterval <= 0 || s.fastInterval >= base || s.lastHit.IsZero() {
+		return base
+	}
+	since := s.clock.Now().Sub(s.lastHit)
+	if since >= s.fastWindow {
+		return base
+	}
+	return s.fastInterval + time.Duration(float64(base-s.fastInterval)*float64(since)/float64(s.fastWindow))
+}
+
+// idleInterval stretches d while nothing is happening: from the s.idleAfter-th
+// consecutive "no slots" page on, each one adds s.idleStep, up to s.idleMax.
+// Any other outcome (idle false) resets the ramp.
+func (s *sniper) idleInterval(idle bool, d time.Duration) time.Duration {
+	if s.idleAfter <= 0 {
+		return d
+	}
+	if !idle {
+		if s.idleStreak >= s.idleAfter {
+			s.log.Info("activity after idle period, back to the normal interval", "idle_checks", s.idleStreak)
+		}
+		s.idleStreak = 0
+		return d
+	}
+	s.idleStreak++
+	if s.idleStreak < s.idleAfter {
+		return d
+	}
+	steps := s.idleStreak - s.idleAfter + 1
+	ramped := min(d+time.Duration(steps)*s.idleStep, s.idleMax)
+	if ramped <= d {
+		return d
+	}
+	if d+time.Duration(steps-1)*s.idleStep < s.idleMax {
+		// Still ramping up; quiet once at the cap.
+		s.log.Info("idle, slowing down", "idle_checks", s.idleStreak, "interval", ramped.String())
+	}
+	return ramped
+}
+
+// runOnce starts a browser, runs a single check and closes the browser again.
+func (s *sniper) runOnce(ctx context.Context) outcome {
+	browserCtx, closeBrowser := s.startBrowser(ctx)
+	defer closeBrowser()
+	o, _ := s.checkOnce(ctx, browserCtx)
+	return o
+}
+
+// docTiming tracks the network timing of the latest document request, which
+// at the end of a check is the appointment page. Its methods are called from
+// the network listener and the check loop.
+type docTiming struct {
+	mu        sync.Mutex
+	requestID network.RequestID
+	start     float64 // the request's start on Chrome's monotonic clock, in seconds
+	ttfb      time.Duration
+	total     time.Duration
+}
+
+func (t *docTiming) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requestID, t.start, t.ttfb, t.total = "", 0, 0, 0
+}
+
+// responded records the time to first byte of a document response.
+func (t *docTiming) responded(e *network.EventResponseReceived) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requestID, t.start, t.ttfb, t.total = e.RequestID, 0, 0, 0
+	if rt := e.Response.Timing; rt != nil {
+		t.start = rt.RequestTime
+		t.ttfb = time.Duration(rt.ReceiveHeadersEnd * float64(time.Millisecond))
+	}
+}
+
+// finished records the total load time once the document has been received.
+func (t *docTiming) finished(e *network.EventLoadingFinished) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e.RequestID != t.requestID || t.start == 0 || e.Timestamp == nil {
+		return
+	}
+	end := e.Timestamp.Time().Sub(*cdp.MonotonicTimeEpoch).Seconds()
+	t.total = time.Duration((end - t.start) * float64(time.Second))
+}
+
+// get returns the time to first byte and the total load time, both 0 while
+// the document is still loading.
+func (t *docTiming) get() (ttfb, total time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.total <= 0 {
+		return 0, 0
+	}
+	return t.ttfb, t.total
+}
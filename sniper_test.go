@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSmartInterval(t *testing.T) {
+	clock := newFakeClock()
+	s := &sniper{
+		log:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		clock:        clock,
+		fastInterval: 10 * time.Second,
+		fastWindow:   10 * time.Minute,
+	}
+	base := 70 * time.Second
+
+	if got := s.smartInterval(base); got != base {
+		t.Fatalf("before any hit: %v, want %v", got, base)
+	}
+	s.markHit()
+	if got := s.smartInterval(base); got != 10*time.Second {
+		t.Fatalf("right after a hit: %v, want 10s", got)
+	}
+	clock.Advance(5 * time.Minute)
+	if got := s.smartInterval(base); got != 40*time.Second {
+		t.Fatalf("halfway through the window: %v, want 40s", got)
+	}
+	clock.Advance(5 * time.Minute)
+	if got := s.smartInterval(base); got != base {
+		t.Fatalf("after the window: %v, want %v", got, base)
+	}
+	if got := s.smartInterval(5 * time.Second); got != 5*time.Second {
+		t.Fatalf("base below the floor: %v, want 5s", got)
+	}
+}
+
+func TestSiteState(t *testing.T) {
+	cfg := &Config{MaintenanceHeadline: "Wartung"}
+	tests := []struct {
+		o    outcome
+		p    pageState
+		want string
+	}{
+		{outcomeSuccess, pageState{status: 200}, "available"},
+		{outcomeKnown, pageState{status: 200, bodyID: "taken"}, "taken"},
+		{outcomeKnown, pageState{status: 200, headline: "Wartungsarbeiten"}, "maintenance"},
+		{outcomeKnown, pageState{status: 429}, "rate-limited"},
+		{outcomeCaptcha, pageState{status: 200}, "captcha"},
+		{outcomeError, pageState{}, "error"},
+	}
+	for _, tt := range tests {
+		if got := siteState(tt.o, tt.p, cfg); got != tt.want {
+			t.Errorf("siteState(%s, %+v) = %s, want %s", tt.o, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestIdleInterval(t *testing.T) {
+	s := &sniper{
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		idleAfter: 3,
+		idleStep:  time.Minute,
+		idleMax:   4 * time.Minute,
+	}
+	base := time.Minute
+	var got []time.Duration
+	for range 6 {
+		got = append(got, s.idleInterval(true, base))
+	}
+	want := []time.Duration{time.Minute, time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	if !slices.Equal(got, want) {
+		t.Errorf("ramp = %v, want %v", got, want)
+	}
+	if d := s.idleInterval(false, base); d != base {
+		t.Errorf("after activity: %v, want %v", d, base)
+	}
+	if d := s.idleInterval(true, base); d != base {
+		t.Errorf("first idle check after a reset: %v, want %v", d, base)
+	}
+}
+
+func TestPostSuccessPause(t *testing.T) {
+	cfg := &Config{}
+	cfg.validate()
+	rec := &recordingNotifier{}
+	clock := newFakeClock()
+	pause := newBookingPause(10*time.Minute, clock)
+	s := &sniper{
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		clock:     clock,
+		cfg:       cfg,
+		backoff:   newBackoffState(time.Minute, time.Hour),
+		throttle:  newThrottle(5, 0, realClock{}),
+		notifiers: []Notifier{rec},
+		pause:     pause,
+	}
+	other := &sniper{pause: pause} // another service's loop
+	page := pageState{status: 200, bodyID: "dayselect"}
+	o, retryEvery, _ := s.classify(context.Background(), context.Background(), page)
+	if o != outcomeSuccess || len(rec.sent) != 1 {
+		t.Fatalf("outcome %s, %d notifications; want a notified success", o, len(rec.sent))
+	}
+	if retryEvery < 10*time.Minute || s.holdOff < 10*time.Minute || !s.pausedForBooking {
+		t.Errorf("retry in %s, hold off %s; want the 10m pause", retryEvery, s.holdOff)
+	}
+	if d := other.pause.remaining(); d != 10*time.Minute {
+		t.Errorf("other service paused for %s, want 10m", d)
+	}
+	clock.Advance(4 * time.Minute)
+	if d := other.pause.remaining(); d != 6*time.Minute {
+		t.Errorf("after 4m: %s left, want 6m", d)
+	}
+	pause.end()
+	if d := other.pause.remaining(); d != 0 {
+		t.Errorf("after end: %s left, want 0", d)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// storeRecent is how many outcomes a Store keeps for RecentOutcomes.
+const storeRecent = 50
+
+// Store keeps a sniper's state between checks: its notification throttles and
+// its latest check outcomes. Each sniper has its own Store.
+type Store interface {
+	SaveThrottle(t throttle) error
+	// LoadThrottle restores t from a previous SaveThrottle. Nothing saved yet
+	// is not an error and leaves t unchanged.
+	LoadThrottle(t throttle) error
+	// SaveNotifierThrottles saves the notifier_throttles, by notifier name.
+	SaveNotifierThrottles(ts map[string]throttle) error
+	// LoadNotifierThrottles restores the throttles in ts that a previous
+	// SaveNotifierThrottles saved under the same name; the others are left
+	// unchanged.
+	LoadNotifierThrottles(ts map[string]throttle) error
+	RecordOutcome(e auditEntry) error
+	// RecentOutcomes returns up to n of the latest outcomes, oldest first.
+	RecentOutcomes(n int) []auditEntry
+}
+
+// newStore returns an fsStore on path, or a memStore when path is empty.
+func newStore(path string) Store {
+	if path == "" {
+		return &memStore{}
+	}
+	return newFSStore(path)
+}
+
+// memStore keeps state in memory only, so it is lost on restart.
+type memStore struct {
+	throttle          []byte
+	notifierThrottles []byte
+	outcomes          []auditEntry
+}
+
+func (m *memStore) SaveThrottle(t throttle) error {
+	data, err := t.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	m.throttle = data
+	return nil
+}
+
+func (m *memStore) LoadThrottle(t throttle) error {
+	if m.throttle == nil {
+		return nil
+	}
+	return t.UnmarshalJSON(m.throttle)
+}
+
+func (m *memStore) SaveNotifierThrottles(ts map[string]throttle) error {
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return err
+	}
+	m.notifierThrottles = data
+	return nil
+}
+
+func (m *memStore) LoadNotifierThrottles(ts map[string]throttle) error {
+	if m.notifierThrottles == nil {
+		return nil
+	}
+	var saved map[string]json.RawMessage
+	if err := json.Unmarshal(m.notifierThrottles, &saved); err != nil {
+		return err
+	}
+	return restoreThrottles(ts, saved)
+}
+
+func (m *memStore) RecordOutcome(e auditEntry) error {
+	m.outcomes = appendRecent(m.outcomes, e)
+	return nil
+}
+
+func (m *memStore) RecentOutcomes(n int) []auditEntry {
+	return lastN(m.outcomes, n)
+}
+
+// fsStore keeps the throttle in a JSON file at path (the --state-file), and
+// next to it the per-notifier throttles in "<path>-notifiers.json" and the
+// recent outcomes in "<path>-outcomes.json". All are replaced atomically on
+// every save.
+type fsStore struct {
+	path          string
+	notifiersPath string
+	outcomesPath  string
+	outcomes      []auditEntry // loaded lazily from outcomesPath
+	loaded        bool
+}
+
+func newFSStore(path string) *fsStore {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return &fsStore{path: path, notifiersPath: base + "-notifiers" + ext, outcomesPath: base + "-outcomes" + ext}
+}
+
+func (f *fsStore) SaveThrottle(t throttle) error {
+	return saveJSON(f.path, t)
+}
+
+func (f *fsStore) LoadThrottle(t throttle) error {
+	return loadJSON(f.path, t)
+}
+
+func (f *fsStore) SaveNotifierThrottles(ts map[string]throttle) error {
+	return saveJSON(f.notifiersPath, ts)
+}
+
+func (f *fsStore) LoadNotifierThrottles(ts map[string]throttle) error {
+	var saved map[string]json.RawMessage
+	if err := loadJSON(f.notifiersPath, &saved); err != nil {
+		return err
+	}
+	return restoreThrottles(ts, saved)
+}
+
+func (f *fsStore) RecordOutcome(e auditEntry) error {
+	if err := f.load(); err != nil {
+		return err
+	}
+	f.outcomes = appendRecent(f.outcomes, e)
+	return saveJSON(f.outcomesPath, f.outcomes)
+}
+
+func (f *fsStore) RecentOutcomes(n int) []auditEntry {
+	if f.load() != nil {
+		return nil
+	}
+	return lastN(f.outcomes, n)
+}
+
+func (f *fsStore) load() error {
+	if f.loaded {
+		return nil
+	}
+	if err := loadJSON(f.outcomesPath, &f.outcomes); err != nil {
+		return err
+	}
+	f.loaded = true
+	return nil
+}
+
+// restoreThrottles restores each throttle in ts from its saved state, if any.
+func restoreThrottles(ts map[string]throttle, saved map[string]json.RawMessage) error {
+	for name, t := range ts {
+		if data, ok := saved[name]; ok {
+			if err := t.UnmarshalJSON(data); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// appendRecent appends e to outcomes, keeping at most storeRecent.
+func appendRecent(outcomes []auditEntry, e auditEntry) []auditEntry {
+	outcomes = append(outcomes, e)
+	if len(outcomes) > storeRecent {
+		outcomes = outcomes[len(outcomes)-storeRecent:]
+	}
+	return outcomes
+}
+
+func lastN(outcomes []auditEntry, n int) []auditEntry {
+	if n < len(outcomes) {
+		outcomes = outcomes[len(outcomes)-n:]
+	}
+	return append([]auditEntry(nil), outcomes...)
+}
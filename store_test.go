@@ -0,0 +1,91 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func() Store{
+		"memory": func() Store { return &memStore{} },
+		"fs": func() Store {
+			path := filepath.Join(t.TempDir(), "state.json")
+			return newFSStore(path)
+		},
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			st := open()
+			th := newNotifyThrottle(1)
+			if err := st.LoadThrottle(th); err != nil {
+				t.Fatalf("load before save: %v", err)
+			}
+			th.onSuccess()
+			th.onSuccess()
+			if err := st.SaveThrottle(th); err != nil {
+				t.Fatal(err)
+			}
+			restored := newNotifyThrottle(1)
+			if err := st.LoadThrottle(restored); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := restored.String(), th.String(); got != want {
+				t.Errorf("restored throttle %s, want %s", got, want)
+			}
+
+			clock := &fakeClock{now: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+			if err := st.LoadNotifierThrottles(map[string]throttle{"email": newNotifyThrottle(1)}); err != nil {
+				t.Fatalf("load notifier throttles before save: %v", err)
+			}
+			own := map[string]throttle{"email": newNotifyThrottle(1), "telegram": newCooldownThrottle(time.Hour, clock)}
+			for _, th := range own {
+				th.onSuccess()
+			}
+			if err := st.SaveNotifierThrottles(own); err != nil {
+				t.Fatal(err)
+			}
+			restoredOwn := map[string]throttle{
+				"email":    newNotifyThrottle(1),
+				"telegram": newCooldownThrottle(time.Hour, clock),
+				"slack":    newNotifyThrottle(1),
+			}
+			if err := st.LoadNotifierThrottles(restoredOwn); err != nil {
+				t.Fatal(err)
+			}
+			for name, th := range own {
+				if got, want := restoredOwn[name].String(), th.String(); got != want {
+					t.Errorf("restored %s throttle %s, want %s", name, got, want)
+				}
+			}
+			if got, want := restoredOwn["slack"].String(), newNotifyThrottle(1).String(); got != want {
+				t.Errorf("slack throttle %s, want it unchanged (%s) as nothing was saved for it", got, want)
+			}
+
+			start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+			for i := range storeRecent + 5 {
+				if err := st.RecordOutcome(auditEntry{Time: start.Add(time.Duration(i) * time.Minute), Outcome: "known"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := len(st.RecentOutcomes(1000)); got != storeRecent {
+				t.Errorf("kept %d outcomes, want %d", got, storeRecent)
+			}
+			last := st.RecentOutcomes(2)
+			if len(last) != 2 || !last[1].Time.Equal(start.Add((storeRecent+4)*time.Minute)) {
+				t.Errorf("RecentOutcomes(2) = %v, want the latest two, oldest first", last)
+			}
+		})
+	}
+}
+
+func TestFSStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := newFSStore(path).RecordOutcome(auditEntry{Outcome: "success"}); err != nil {
+		t.Fatal(err)
+	}
+	got := newFSStore(path).RecentOutcomes(5)
+	if len(got) != 1 || got[0].Outcome != "success" {
+		t.Errorf("after reopening: %v, want the one recorded outcome", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SuccessText is a success criterion on the text of the booking page, for
+// services whose body id doesn't tell a calendar from "Kein Termin frei".
+// Matching ignores case.
+type SuccessText struct {
+	Contains    []string `yaml:"contains"`     // texts the page must show
+	NotContains []string `yaml:"not_contains"` // texts the page must not show
+	Match       string   `yaml:"match"`        // all (default) or any of the conditions above
+	Combine     string   `yaml:"combine"`      // and (default) or or, with the body id check
+
+	// Selectors are tried in order like headline_selectors, which they
+	// default to; the text of the first match with any is what is matched.
+	// ["body"] matches the whole page.
+	Selectors []string `yaml:"selectors"`
+}
+
+// successTextSelectors returns where success_text looks on the page.
+func (cfg *Config) successTextSelectors() []string {
+	if cfg.SuccessText != nil && len(cfg.SuccessText.Selectors) > 0 {
+		return cfg.SuccessText.Selectors
+	}
+	return cfg.HeadlineSelectors
+}
+
+// active reports whether any text condition is configured.
+func (t *SuccessText) active() bool {
+	return t != nil && len(t.Contains)+len(t.NotContains) > 0
+}
+
+// validate checks t, filling in the defaults, and returns a problem
+// description, or "" if it is valid.
+func (t *SuccessText) validate() string {
+	if t.Match == "" {
+		t.Match = "all"
+	}
+	if t.Combine == "" {
+		t.Combine = "and"
+	}
+	t.Selectors = selectors(t.Selectors)
+	switch {
+	case t.Match != "all" && t.Match != "any":
+		return fmt.Sprintf("unknown match %q (want all or any)", t.Match)
+	case t.Combine != "and" && t.Combine != "or":
+		return fmt.Sprintf("unknown combine %q (want and or or)", t.Combine)
+	}
+	return ""
+}
+
+// matches reports whether text satisfies t: all of its conditions, or any of
+// them with match: any.
+func (t *SuccessText) matches(text string) bool {
+	text = strings.ToLower(text)
+	var conds []bool
+	for _, s := range t.Contains {
+		conds = append(conds, strings.Contains(text, strings.ToLower(s)))
+	}
+	for _, s := range t.NotContains {
+		conds = append(conds, !strings.Contains(text, strings.ToLower(s)))
+	}
+	for _, ok := range conds {
+		if ok == (t.Match == "any") {
+			return ok
+		}
+	}
+	return t.Match != "any"
+}
+
+// decide combines the body id check with t for page text.
+func (t *SuccessText) decide(byBodyID bool, text string) bool {
+	if !t.active() {
+		return byBodyID
+	}
+	if t.Combine == "or" {
+		return byBodyID || t.matches(text)
+	}
+	return byBodyID && t.matches(text)
+}
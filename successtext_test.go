@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestSuccessText(t *testing.T) {
+	calendar := pageState{status: 200, bodyID: "dayselect", text: "Bitte wählen Sie ein Datum"}
+	none := pageState{status: 200, bodyID: "dayselect", text: "Leider sind aktuell keine Termine für ihre Auswahl verfügbar. KEIN TERMIN FREI"}
+	plain := pageState{status: 200, bodyID: "page", text: "Bitte wählen Sie ein Datum"}
+
+	for _, tc := range []struct {
+		name string
+		st   *SuccessText
+		want [3]bool // calendar, none, plain
+	}{
+		{"unset", nil, [3]bool{true, true, false}},
+		{"not_contains", &SuccessText{NotContains: []string{"kein termin frei"}}, [3]bool{true, false, false}},
+		{"contains and not_contains", &SuccessText{Contains: []string{"Datum"}, NotContains: []string{"Kein Termin"}}, [3]bool{true, false, false}},
+		{"match any", &SuccessText{Contains: []string{"Datum", "Uhrzeit"}, Match: "any"}, [3]bool{true, false, false}},
+		{"match all", &SuccessText{Contains: []string{"Datum", "Uhrzeit"}}, [3]bool{false, false, false}},
+		{"combine or", &SuccessText{Contains: []string{"Datum"}, Combine: "or"}, [3]bool{true, true, true}},
+	} {
+		cfg := &Config{SuccessBodyIDs: []string{"dayselect"}, SuccessText: tc.st}
+		if tc.st != nil {
+			if p := tc.st.validate(); p != "" {
+				t.Fatalf("%s: %s", tc.name, p)
+			}
+		}
+		for i, p := range []pageState{calendar, none, plain} {
+			if got := cfg.isSuccessPage(p); got != tc.want[i] {
+				t.Errorf("%s: page %d success = %v, want %v", tc.name, i, got, tc.want[i])
+			}
+		}
+	}
+
+	if p := (&SuccessText{Contains: []string{"x"}, Match: "some"}).validate(); p == "" {
+		t.Error("want a problem for an unknown match")
+	}
+}
+
+func TestSuccessTextSelectors(t *testing.T) {
+	cfg := &Config{SuccessText: &SuccessText{NotContains: []string{"kein termin"}}}
+	cfg.validate()
+	if got := cfg.successTextSelectors(); len(got) != 2 || got[0] != "h2" {
+		t.Fatalf("selectors = %q, want headline_selectors", got)
+	}
+
+	// The footer says "Kein Termin frei? Rufen Sie 115 an", but it isn't
+	// among the selectors, so only the headline decides.
+	sels := []string{"h2", "h1"}
+	found := [][]string{{"", "Bitte wählen Sie ein Datum"}, {"Terminvereinbarung"}}
+	sel, text := firstText(sels, found)
+	if sel != "h2" || text != "Bitte wählen Sie ein Datum" {
+		t.Fatalf("firstText = %q, %q; want the first h2 with text", sel, text)
+	}
+	p := pageState{status: 200, bodyID: "dayselect", text: text}
+	if !cfg.isSuccessPage(p) {
+		t.Error("text outside the selected element counted")
+	}
+
+	cfg.SuccessText.Selectors = []string{".footer", "h2"}
+	sel, text = firstText(cfg.successTextSelectors(), [][]string{{"Kein Termin frei? Rufen Sie 115 an"}, {"Bitte wählen Sie ein Datum"}})
+	if sel != ".footer" || cfg.isSuccessPage(pageState{status: 200, bodyID: "dayselect", text: text}) {
+		t.Errorf("own selectors: matched %q, want .footer to decide", sel)
+	}
+	if sel, text := firstText(sels, [][]string{{}, {""}}); sel != "" || text != "" {
+		t.Errorf("nothing matched: got %q, %q", sel, text)
+	}
+}
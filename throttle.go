@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// throttle decides whether a success should produce a notification.
+// Implementations marshal their state to JSON so a Store can persist it and
+// restarts don't re-notify.
+type throttle interface {
+	// onSuccess returns true if a notification should be sent.
+	onSuccess() bool
+	onFailure()
+	json.Marshaler
+	json.Unmarshaler
+	fmt.Stringer
+}
+
+// NotifierThrottle is a notifier's own throttle policy, replacing the global
+// --notify-window/--notify-cooldown throttle for that backend.
+type NotifierThrottle struct {
+	Window   int           `yaml:"window"`
+	Cooldown time.Duration `yaml:"cooldown"`
+	Always   bool          `yaml:"always"` // notify on every success
+}
+
+// newThrottle returns a cooldownThrottle timed by clock when cooldown is set,
+// otherwise the count-based notifyThrottle.
+func newThrottle(window int, cooldown time.Duration, clock Clock) throttle {
+	if cooldown > 0 {
+		return newCooldownThrottle(cooldown, clock)
+	}
+	return newNotifyThrottle(window)
+}
+
+// saveJSON writes v to path as JSON, replacing it atomically.
+func saveJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadJSON reads path into v. A missing file is not an error.
+func loadJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// alwaysThrottle lets every success through.
+type alwaysThrottle struct{}
+
+func (alwaysThrottle) onSuccess() bool                 { return true }
+func (alwaysThrottle) onFailure()                      {}
+func (alwaysThrottle) MarshalJSON() ([]byte, error)    { return []byte("{}"), nil }
+func (alwaysThrottle) UnmarshalJSON(data []byte) error { return nil }
+func (alwaysThrottle) String() string                  { return "always" }
+
+// notifyThrottle suppresses repeated success notifications.
+// It sends freely for the first `window` consecutive successes, then
+// suppresses for the next `window`, then resets and sends one, and repeats.
+type notifyThrottle struct {
+	window      int
+	consecutive int // consecutive successes so far
+	suppressed  int // how many we have suppressed in the current suppression period
+}
+
+func newNotifyThrottle(window int) *notifyThrottle {
+	return &notifyThrottle{window: window}
+}
+
+// onSuccess returns true if a notification should be sent.
+func (t *notifyThrottle) onSuccess() bool {
+	t.consecutive++
+
+	if t.suppressed > 0 {
+		// Currently in suppression period.
+		t.suppressed++
+		if t.suppressed > t.window {
+			// Suppression period over: reset and send one.
+			t.consecutive = 0
+			t.suppressed = 0
+			return true
+		}
+		return false
+	}
+
+	if t.consecutive >= t.window {
+		// Just crossed the threshold: enter suppression.
+		t.suppressed = 1
+		return false
+	}
+
+	return true
+}
+
+// onFailure resets all state.
+func (t *notifyThrottle) onFailure() {
+	t.consecutive = 0
+	t.suppressed = 0
+}
+
+// throttleState is the on-disk form of notifyThrottle's counters.
+type throttleState struct {
+	Consecutive int `json:"consecutive"`
+	Suppressed  int `json:"suppressed"`
+}
+
+func (t *notifyThrottle) MarshalJSON() ([]byte, error) {
+	return json.Marshal(throttleState{Consecutive: t.consecutive, Suppressed: t.suppressed})
+}
+
+func (t *notifyThrottle) UnmarshalJSON(data []byte) error {
+	var st throttleState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	t.consecutive = st.Consecutive
+	t.suppressed = st.Suppressed
+	return nil
+}
+
+func (t *notifyThrottle) String() string {
+	return fmt.Sprintf("consecutive=%d suppressed=%d", t.consecutive, t.suppressed)
+}
+
+// cooldownThrottle allows one notification and then suppresses all further
+// ones until cooldown has passed, regardless of how many checks happen.
+type cooldownThrottle struct {
+	cooldown time.Duration
+	clock    Clock
+	lastSent time.Time
+}
+
+func newCooldownThrottle(cooldown time.Duration, clock Clock) *cooldownThrottle {
+	return &cooldownThrottle{cooldown: cooldown, clock: clock}
+}
+
+func (t *cooldownThrottle) onSuccess() bool {
+	now := t.clock.Now()
+	if !t.lastSent.IsZero() && now.Sub(t.lastSent) < t.cooldown {
+		return false
+	}
+	t.lastSent = now
+	return true
+}
+
+// onFailure does nothing: the cooldown runs on wall-clock time.
+func (t *cooldownThrottle) onFailure() {}
+
+// cooldownState is the on-disk form of cooldownThrottle.
+type cooldownState struct {
+	LastSent time.Time `json:"last_sent"`
+}
+
+func (t *cooldownThrottle) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cooldownState{LastSent: t.lastSent})
+}
+
+func (t *cooldownThrottle) UnmarshalJSON(data []byte) error {
+	var st cooldownState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	t.lastSent = st.LastSent
+	return nil
+}
+
+func (t *cooldownThrottle) String() string {
+	if t.lastSent.IsZero() {
+		return "cooldown idle"
+	}
+	return "cooldown until " + t.lastSent.Add(t.cooldown).Format(time.DateTime)
+}
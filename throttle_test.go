@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runThrottle feeds events to th, 's' for a success and 'f' for a failure,
+// and returns the onSuccess results as a string: 'n' notified, '-' suppressed,
+// and '.' for each failure.
+func runThrottle(th throttle, events string) string {
+	var b strings.Builder
+	for _, e := range events {
+		switch e {
+		case 's':
+			if th.onSuccess() {
+				b.WriteByte('n')
+			} else {
+				b.WriteByte('-')
+			}
+		case 'f':
+			th.onFailure()
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
+
+func TestNotifyThrottle(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int
+		events string
+		want   string
+	}{
+		{
+			name:   "window 1",
+			window: 1,
+			events: "ssssss",
+			want:   "-n-n-n",
+		},
+		{
+			name:   "first window suppresses its last success",
+			window: 3,
+			events: "sss",
+			want:   "nn-",
+		},
+		{
+			name:   "long run of successes",
+			window: 3,
+			events: "ssssssssssssss",
+			want:   "nn---nnn---nnn",
+		},
+		{
+			name:   "reset and send boundary",
+			window: 2,
+			events: "sssss",
+			want:   "n--nn",
+		},
+		{
+			name:   "failure mid-suppression starts over",
+			window: 3,
+			events: "sssssfsssss",
+			want:   "nn---.nn---",
+		},
+		{
+			name:   "failure before the window fills",
+			window: 3,
+			events: "ssfsss",
+			want:   "nn.nn-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runThrottle(newNotifyThrottle(tt.window), tt.events); got != tt.want {
+				t.Errorf("events %s: got %s, want %s", tt.events, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifyThrottlePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	th := newNotifyThrottle(2)
+	if got := runThrottle(th, "sss"); got != "n--" {
+		t.Fatalf("before save: got %s, want n--", got)
+	}
+	if err := newFSStore(path).SaveThrottle(th); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newNotifyThrottle(2)
+	if err := newFSStore(path).LoadThrottle(restored); err != nil {
+		t.Fatal(err)
+	}
+	if got := runThrottle(restored, "ss"); got != "nn" {
+		t.Errorf("after load: got %s, want nn", got)
+	}
+}
+
+func TestAllowedNotifiers(t *testing.T) {
+	s := &sniper{
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		notifiers: []Notifier{bellNotifier{count: 1}, &telegramNotifier{}, &webhookNotifier{}},
+		throttle:  newNotifyThrottle(1),
+		backoff:   newBackoffState(time.Minute, time.Hour),
+		clock:     newFakeClock(),
+	}
+	s.setConfig(&Config{NotifierThrottles: map[string]NotifierThrottle{
+		"telegram": {Always: true},
+		"webhook":  {Cooldown: time.Hour},
+	}})
+	s.notifiers = []Notifier{bellNotifier{count: 1}, &telegramNotifier{}, &webhookNotifier{}}
+
+	names := func(global bool) string {
+		var got []string
+		for _, n := range s.allowedNotifiers(global) {
+			got = append(got, n.Name())
+		}
+		return strings.Join(got, ",")
+	}
+	if got := names(true); got != "bell,telegram,webhook" {
+		t.Errorf("first success: got %s", got)
+	}
+	if got := names(false); got != "telegram" {
+		t.Errorf("global throttle closed, webhook cooling down: got %s, want telegram", got)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// traceExportTimeout bounds one export to the collector.
+const traceExportTimeout = 10 * time.Second
+
+// tracer sends one OpenTelemetry trace per check to an OTLP/HTTP collector
+// (--otel-endpoint), encoded as OTLP JSON so no SDK is needed. A nil *tracer
+// is valid: its spans are nil and every span method does nothing, so
+// tracing costs nothing unless it is configured.
+type tracer struct {
+	url     string // the collector's /v1/traces
+	version string
+
+	mu     sync.Mutex
+	failed bool // the last export failed; logged once per failure streak
+}
+
+// newTracer returns nil when endpoint is empty, disabling tracing.
+func newTracer(endpoint string) (*tracer, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http/https URL", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return &tracer{url: u.String(), version: versionString()}, nil
+}
+
+// span is one timed operation of a trace. Children share their root's
+// trace; ending the root exports the whole trace.
+type span struct {
+	t      *tracer
+	trace  *traceBuf
+	id     [8]byte
+	parent [8]byte
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]any
+	err    string
+}
+
+// traceBuf collects the finished spans of one trace.
+type traceBuf struct {
+	id    [16]byte
+	mu    sync.Mutex
+	spans []*span
+}
+
+// root starts the span of a new trace.
+func (t *tracer) root(name string) *span {
+	if t == nil {
+		return nil
+	}
+	tb := &traceBuf{}
+	rand.Read(tb.id[:])
+	return newSpan(t, tb, [8]byte{}, name)
+}
+
+func newSpan(t *tracer, tb *traceBuf, parent [8]byte, name string) *span {
+	sp := &span{t: t, trace: tb, parent: parent, name: name, start: time.Now(), attrs: make(map[string]any)}
+	rand.Read(sp.id[:])
+	return sp
+}
+
+// child starts a span below sp. It is safe to call from several goroutines.
+func (sp *span) child(name string) *span {
+	if sp == nil {
+		return nil
+	}
+	return newSpan(sp.t, sp.trace, sp.id, name)
+}
+
+// set adds an attribute; strings, bools, integers and floats are kept as
+// such, anything else as its string form.
+func (sp *span) set(key string, v any) {
+	if sp == nil {
+		return
+	}
+	sp.attrs[key] = v
+}
+
+// fail marks the span as failed with err, if err is not nil.
+func (sp *span) fail(err error) {
+	if sp == nil || err == nil {
+		return
+	}
+	sp.err = err.Error()
+}
+
+// finish ends sp. Finishing the root exports the trace in the background.
+func (sp *span) finish() {
+	if sp == nil {
+		return
+	}
+	sp.end = time.Now()
+	sp.trace.mu.Lock()
+	sp.trace.spans = append(sp.trace.spans, sp)
+	spans := sp.trace.spans
+	sp.trace.mu.Unlock()
+	if sp.parent == ([8]byte{}) {
+		go sp.t.export(sp.trace.id, spans)
+	}
+}
+
+// traced runs a inside a child span of sp named name.
+func (sp *span) traced(name string, a chromedp.Action) chromedp.Action {
+	if sp == nil {
+		return a
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		c := sp.child(name)
+		err := a.Do(ctx)
+		c.fail(err)
+		c.finish()
+		return err
+	})
+}
+
+// export posts spans to the collector, logging the first failure of a
+// streak and the recovery after it.
+func (t *tracer) export(traceID [16]byte, spans []*span) {
+	body, err := json.Marshal(t.otlp(traceID, spans))
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), traceExportTimeout)
+		defer cancel()
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			var resp *http.Response
+			resp, err = http.DefaultClient.Do(req)
+			if err == nil {
+				err = checkStatus(resp)
+				resp.Body.Close()
+			}
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case err != nil && !t.failed:
+		log.Printf("otel: could not export trace (%v) — will keep trying", redactErr(err))
+	case err == nil && t.failed:
+		log.Printf("otel: exporting traces again")
+	}
+	t.failed = err != nil
+}
+
+// otlp renders spans as an OTLP/JSON ExportTraceServiceRequest.
+func (t *tracer) otlp(traceID [16]byte, spans []*span) map[string]any {
+	var out []map[string]any
+	for _, sp := range spans {
+		s := map[string]any{
+			"traceId":           hex.EncodeToString(traceID[:]),
+			"spanId":            hex.EncodeToString(sp.id[:]),
+			"name":              sp.name,
+			"kind":              1, // SPAN_KIND_INTERNAL
+			"startTimeUnixNano": strconv.FormatInt(sp.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(sp.end.UnixNano(), 10),
+			"attributes":        otlpAttrs(sp.attrs),
+		}
+		if sp.parent != ([8]byte{}) {
+			s["parentSpanId"] = hex.EncodeToString(sp.parent[:])
+		}
+		if sp.err != "" {
+			s["status"] = map[string]any{"code": 2, "message": sp.err} // STATUS_CODE_ERROR
+		}
+		out = append(out, s)
+	}
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource": map[string]any{"attributes": otlpAttrs(map[string]any{
+			"service.name":    "terminator",
+			"service.version": t.version,
+		})},
+		"scopeSpans": []any{map[string]any{
+			"scope": map[string]any{"name": "terminator"},
+			"spans": out,
+		}},
+	}}}
+}
+
+// otlpAttrs converts attributes to OTLP KeyValues.
+func otlpAttrs(attrs map[string]any) []map[string]any {
+	var out []map[string]any
+	for _, k := range slices.Sorted(maps.Keys(attrs)) {
+		v := attrs[k]
+		var val map[string]any
+		switch v := v.(type) {
+		case string:
+			val = map[string]any{"stringValue": v}
+		case bool:
+			val = map[string]any{"boolValue": v}
+		case int:
+			val = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			val = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			val = map[string]any{"doubleValue": v}
+		default:
+			val = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]any{"key": k, "value": val})
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracerDisabled(t *testing.T) {
+	tr, err := newTracer("")
+	if tr != nil || err != nil {
+		t.Fatalf("newTracer(\"\") = %v, %v; want nil, nil", tr, err)
+	}
+	sp := tr.root("check")
+	sp.child("classify").finish()
+	sp.set("outcome", "known")
+	sp.fail(errors.New("boom"))
+	sp.finish() // must not panic
+
+	if _, err := newTracer("localhost:4318"); err == nil {
+		t.Error("want an error for an endpoint without scheme")
+	}
+}
+
+func TestTracerExport(t *testing.T) {
+	type otlpSpan struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Attributes   []struct {
+			Key   string         `json:"key"`
+			Value map[string]any `json:"value"`
+		} `json:"attributes"`
+		Status *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	got := make(chan []otlpSpan, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		got <- req.ResourceSpans[0].ScopeSpans[0].Spans
+	}))
+	defer srv.Close()
+
+	tr, err := newTracer(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tr.root("check")
+	root.set("http.status", int64(200))
+	root.set("outcome", "known")
+	nav := root.child("navigate service page")
+	nav.fail(errors.New("net::ERR_TIMED_OUT"))
+	nav.finish()
+	root.finish()
+
+	var spans []otlpSpan
+	select {
+	case spans = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trace exported")
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	child, parent := spans[0], spans[1]
+	if child.TraceID != parent.TraceID || child.ParentSpanID != parent.SpanID || parent.ParentSpanID != "" {
+		t.Errorf("spans are not linked: %+v", spans)
+	}
+	if child.Status == nil || child.Status.Code != 2 {
+		t.Errorf("failed span status = %+v, want error", child.Status)
+	}
+	if len(parent.Attributes) != 2 || parent.Attributes[0].Key != "http.status" || parent.Attributes[0].Value["intValue"] != "200" {
+		t.Errorf("root attributes = %+v", parent.Attributes)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// tuiLogLines is how many log lines the TUI keeps for scrolling.
+const tuiLogLines = 500
+
+// tui is the --tui terminal UI: a status line per service with the
+// countdown to its next check, the last outcome and the throttle state, and
+// the log scrolling below. Keys drive the snipe loops: c (or space) checks
+// now, p pauses and resumes, q quits. A nil *tui is valid and does nothing.
+type tui struct {
+	mu       sync.Mutex
+	services []string              // in order of appearance
+	latest   map[string]auditEntry // by service name
+	throttle map[string]string     // by service name
+	next     map[string]time.Time  // by service name; zero while checking
+	lines    []string              // log output, oldest first
+	partial  string                // log output after the last newline
+	resumed  chan struct{}         // non-nil while paused; closed on resume
+	checkNow []chan struct{}       // one per sniper, see newCheckNow
+}
+
+func newTUI() *tui {
+	return &tui{
+		latest:   make(map[string]auditEntry),
+		throttle: make(map[string]string),
+		next:     make(map[string]time.Time),
+	}
+}
+
+// newCheckNow returns the "check now" channel for one sniper: a send ends
+// its current wait early. It is nil, and never fires, without a TUI.
+func (t *tui) newCheckNow() chan struct{} {
+	if t == nil {
+		return nil
+	}
+	ch := make(chan struct{}, 1)
+	t.mu.Lock()
+	t.checkNow = append(t.checkNow, ch)
+	t.mu.Unlock()
+	return ch
+}
+
+// record adds a completed check and the throttle state after it.
+func (t *tui) record(e auditEntry, throttle string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(e.Service)
+	t.latest[e.Service] = e
+	t.throttle[e.Service] = throttle
+	t.next[e.Service] = time.Time{}
+}
+
+// scheduled records when service checks next.
+func (t *tui) scheduled(service string, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(service)
+	t.next[service] = at
+}
+
+func (t *tui) add(service string) {
+	if !slices.Contains(t.services, service) {
+		t.services = append(t.services, service)
+	}
+}
+
+// waitResumed blocks while the TUI is paused. A send on checkNow lets one
+// check through anyway. It returns false if ctx was cancelled.
+func (t *tui) waitResumed(ctx context.Context, checkNow <-chan struct{}) bool {
+	if t == nil {
+		return ctx.Err() == nil
+	}
+	t.mu.Lock()
+	resumed := t.resumed
+	t.mu.Unlock()
+	if resumed == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-resumed:
+	case <-checkNow:
+	}
+	return true
+}
+
+// paused reports whether the snipe loops are paused.
+func (t *tui) paused() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.resumed != nil
+}
+
+// togglePause pauses or resumes all snipe loops and reports whether they
+// are paused now.
+func (t *tui) togglePause() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.resumed != nil {
+		close(t.resumed)
+		t.resumed = nil
+		return false
+	}
+	t.resumed = make(chan struct{})
+	return true
+}
+
+// triggerCheck ends the current wait of every snipe loop.
+func (t *tui) triggerCheck() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ch := range t.checkNow {
+		select {
+		case ch <- struct{}{}:
+		default: // one is already pending
+		}
+	}
+}
+
+// Write takes the standard log package's output while the TUI runs.
+func (t *tui) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := strings.Split(t.partial+string(p), "\n")
+	t.partial = lines[len(lines)-1]
+	t.lines = append(t.lines, lines[:len(lines)-1]...)
+	if len(t.lines) > tuiLogLines {
+		t.lines = slices.Clone(t.lines[len(t.lines)-tuiLogLines:])
+	}
+	return len(p), nil
+}
+
+// start switches the terminal to raw mode, routes the log into the TUI and
+// redraws it every second until ctx is done; q or Ctrl-C calls quit. The
+// returned func restores the terminal and the log output.
+func (t *tui) start(ctx context.Context, quit func()) (func(), error) {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return nil, fmt.Errorf("stdin and stdout must be a terminal")
+	}
+	saved, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l") // alternate screen, hide cursor
+	log.SetOutput(t)
+
+	go t.readKeys(quit)
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			t.draw(out)
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+	return func() {
+		stop()
+		<-done
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		_ = term.Restore(in, saved)
+		log.SetOutput(os.Stderr)
+		// Leave the last screenful of log in the normal scrollback.
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for _, l := range t.lines[max(len(t.lines)-20, 0):] {
+			fmt.Fprintln(os.Stderr, l)
+		}
+	}, nil
+}
+
+func (t *tui) readKeys(quit func()) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, k := range buf[:n] {
+			switch k {
+			case 'c', ' ', '\r':
+				log.Printf("tui: checking now")
+				t.triggerCheck()
+			case 'p':
+				if t.togglePause() {
+					log.Printf("tui: paused — press p to resume")
+				} else {
+					log.Printf("tui: resumed")
+				}
+			case 'q', 3: // Ctrl-C doesn't raise SIGINT in raw mode
+				log.Printf("tui: quitting")
+				quit()
+				return
+			}
+		}
+	}
+}
+
+// draw renders the whole screen. Raw mode needs "\r\n" line endings.
+func (t *tui) draw(fd int) {
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		width, height = 80, 24
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	state := "running"
+	if t.resumed != nil {
+		state = "PAUSED"
+	}
+	header := []string{fmt.Sprintf("terminator — %s   [c] check now  [p] pause/resume  [q] quit", state)}
+	if len(t.services) == 0 {
+		header = append(header, "  waiting for the first check…")
+	}
+	for _, svc := range t.services {
+		header = append(header, t.serviceLine(svc, now))
+	}
+	header = append(header, strings.Repeat("─", width))
+	for _, l := range header {
+		b.WriteString(clip(l, width) + "\r\n")
+	}
+	rows := max(height-len(header)-1, 0) // the last "\r\n" would scroll
+	for _, l := range t.lines[max(len(t.lines)-rows, 0):] {
+		b.WriteString(clip(l, width) + "\r\n")
+	}
+	fmt.Print(b.String())
+}
+
+// serviceLine is one status line; the caller holds t.mu.
+func (t *tui) serviceLine(svc string, now time.Time) string {
+	name := svc
+	if name == "" {
+		name = "appointments"
+	}
+	next := "checking…"
+	if at := t.next[svc]; !at.IsZero() {
+		next = "next in " + max(at.Sub(now), 0).Round(time.Second).String()
+	}
+	e, ok := t.latest[svc]
+	if !ok {
+		return fmt.Sprintf("  %-14s %s", name, next)
+	}
+	return fmt.Sprintf("  %-14s %-10s status=%d  %s ago  throttle=%s  %s",
+		name, e.Outcome, e.Status, now.Sub(e.Time).Round(time.Second), t.throttle[svc], next)
+}
+
+// clip cuts s to width runes.
+func clip(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTUIPauseAndCheckNow(t *testing.T) {
+	var nilTUI *tui
+	if nilTUI.newCheckNow() != nil || !nilTUI.waitResumed(context.Background(), nil) {
+		t.Fatal("a nil TUI should never pause or fire")
+	}
+
+	ui := newTUI()
+	now := ui.newCheckNow()
+	ctx := context.Background()
+	if !ui.waitResumed(ctx, now) {
+		t.Fatal("waitResumed blocked while running")
+	}
+
+	if !ui.togglePause() {
+		t.Fatal("togglePause did not pause")
+	}
+	done := make(chan bool)
+	go func() { done <- ui.waitResumed(ctx, now) }()
+	select {
+	case <-done:
+		t.Fatal("waitResumed returned while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+	ui.triggerCheck()
+	ui.triggerCheck() // must not block with one already pending
+	if !<-done {
+		t.Fatal("check now did not let a check through")
+	}
+	<-now // drain the second trigger
+
+	go func() { done <- ui.waitResumed(ctx, now) }()
+	if ui.togglePause() {
+		t.Fatal("togglePause did not resume")
+	}
+	if !<-done {
+		t.Fatal("resume did not release waitResumed")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	ui.togglePause()
+	if ui.waitResumed(cctx, now) {
+		t.Error("waitResumed should report a cancelled context")
+	}
+}
+
+func TestTUILog(t *testing.T) {
+	ui := newTUI()
+	fmt.Fprint(ui, "one\ntw")
+	fmt.Fprint(ui, "o\n")
+	if len(ui.lines) != 2 || ui.lines[1] != "two" {
+		t.Fatalf("lines = %q", ui.lines)
+	}
+	for i := range tuiLogLines {
+		fmt.Fprintf(ui, "%d\n", i)
+	}
+	if len(ui.lines) != tuiLogLines || ui.lines[0] != "0" {
+		t.Errorf("kept %d lines starting with %q", len(ui.lines), ui.lines[0])
+	}
+}
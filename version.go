@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build metadata, injected at build time with
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unset values fall back to what the Go toolchain recorded, if anything.
+var version, commit, date string
+
+// versionString describes the build, e.g. "v1.2.0 (commit 1a2b3c4, built
+// 2025-03-01T12:00:00Z)".
+func versionString() string {
+	v, c, d := version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && c == "":
+				c = s.Value
+				if len(c) > 7 {
+					c = c[:7]
+				}
+			case s.Key == "vcs.time" && d == "":
+				d = s.Value
+			}
+		}
+	}
+	if v == "" {
+		v = "dev"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s)", v, c, d)
+}
+
+// printVersion prints the --version output.
+func printVersion() {
+	fmt.Println("terminator " + versionString())
+}